@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/meltred/meltcd/internal/core/application"
 	"github.com/meltred/meltcd/server"
@@ -105,7 +106,7 @@ func getSpecFromData(cmd *cobra.Command, args []string) (application.Spec, error
 			return application.Spec{}, err
 		}
 
-		path, err := cmd.Flags().GetString("path")
+		paths, err := cmd.Flags().GetStringArray("path")
 		if err != nil {
 			return application.Spec{}, err
 		}
@@ -113,11 +114,87 @@ func getSpecFromData(cmd *cobra.Command, args []string) (application.Spec, error
 		refresh, _ := cmd.Flags().GetString("refresh")
 		revision, _ := cmd.Flags().GetString("revision")
 
-		spec, err = application.ParseSpecFromValue(name, repo, revision, path, refresh)
+		spec, err = application.ParseSpecFromValue(name, repo, revision, paths, refresh)
 		if err != nil {
 			return application.Spec{}, err
 		}
+
+		syncPolicy, _ := cmd.Flags().GetString("sync-policy")
+		spec.SyncPolicy = application.SyncPolicy(syncPolicy)
+
+		selfHeal, _ := cmd.Flags().GetBool("self-heal")
+		spec.SelfHeal = &selfHeal
+
+		spec.Prune.Enabled, _ = cmd.Flags().GetBool("prune")
+		spec.Prune.Volumes, _ = cmd.Flags().GetBool("prune-volumes")
+		spec.Prune.DryRun, _ = cmd.Flags().GetBool("prune-dry-run")
+
+		params, _ := cmd.Flags().GetStringArray("param")
+		spec.Parameters, err = parseParams(params)
+		if err != nil {
+			return application.Spec{}, err
+		}
+
+		if isTemplate, _ := cmd.Flags().GetBool("template"); isTemplate {
+			valueFiles, _ := cmd.Flags().GetStringArray("values")
+			set, _ := cmd.Flags().GetStringArray("set")
+
+			values, err := parseParams(set)
+			if err != nil {
+				return application.Spec{}, err
+			}
+
+			spec.Source.Template = &application.TemplateSource{
+				ValueFiles: valueFiles,
+				Values:     values,
+			}
+		}
+
+		if isOverlay, _ := cmd.Flags().GetBool("overlay"); isOverlay {
+			images, _ := cmd.Flags().GetStringArray("image")
+			spec.Source.Overlay = &application.OverlaySource{Images: images}
+		}
+
+		if isApps, _ := cmd.Flags().GetBool("apps"); isApps {
+			spec.Source.Apps = &application.AppsSource{}
+			spec.Source.Apps.Recurse, _ = cmd.Flags().GetBool("recurse")
+		}
+
+		if isJsonnet, _ := cmd.Flags().GetBool("jsonnet"); isJsonnet {
+			spec.Source.Jsonnet = &application.JsonnetSource{}
+			spec.Source.Jsonnet.LibPaths, _ = cmd.Flags().GetStringArray("jpath")
+
+			for flag, out := range map[string]*map[string]string{
+				"ext-str":  &spec.Source.Jsonnet.ExtVars,
+				"ext-code": &spec.Source.Jsonnet.ExtCode,
+				"tla-str":  &spec.Source.Jsonnet.TLAs,
+				"tla-code": &spec.Source.Jsonnet.TLACode,
+			} {
+				values, _ := cmd.Flags().GetStringArray(flag)
+				if *out, err = parseParams(values); err != nil {
+					return application.Spec{}, fmt.Errorf("--%s: %w", flag, err)
+				}
+			}
+		}
 	}
 
 	return spec, nil
 }
+
+// parseParams parses the KEY=VALUE parameters of the service file
+func parseParams(params []string) (map[string]string, error) {
+	if len(params) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[string]string, len(params))
+	for _, p := range params {
+		key, value, ok := strings.Cut(p, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid parameter %q, expected KEY=VALUE", p)
+		}
+		result[key] = value
+	}
+
+	return result, nil
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/meltred/meltcd/internal/core"
+	"github.com/meltred/meltcd/internal/core/application"
+	"github.com/meltred/meltcd/server"
+	api "github.com/meltred/meltcd/server/api/app"
+	"github.com/meltred/meltcd/util"
+	"github.com/spf13/cobra"
+)
+
+func DiffApplication(cmd *cobra.Command, args []string) error {
+	appName := args[0]
+
+	file, _ := cmd.Flags().GetString("file")
+	exitCode, _ := cmd.Flags().GetBool("exit-code")
+
+	method := http.MethodGet
+	var body io.Reader
+
+	// diff against a local service file (like in CI before merging)
+	// instead of the one in git
+	if file != "" {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		buf := new(bytes.Buffer)
+		if err := json.NewEncoder(buf).Encode(api.DiffRequest{Spec: string(content)}); err != nil {
+			return err
+		}
+
+		method = http.MethodPost
+		body = buf
+	}
+
+	req, client, err := server.HTTPRequestWithBearerToken(method, fmt.Sprintf("%s/api/apps/%s/diff", util.GetServer(), appName), body, file != "")
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return server.ReadAuthError(res.Body)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		var resPayload api.GlobalResponse
+		if err := json.NewDecoder(res.Body).Decode(&resPayload); err != nil {
+			return err
+		}
+		return errors.New(resPayload.Message)
+	}
+
+	var result core.DiffResult
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return err
+	}
+
+	if len(result.Services) == 0 {
+		util.Info("Application is in sync, nothing to change")
+		return nil
+	}
+
+	printDiff(result)
+
+	if exitCode {
+		return fmt.Errorf("%d resource(s) will be changed", len(result.Services))
+	}
+
+	return nil
+}
+
+func printDiff(result core.DiffResult) {
+	header := color.New(color.Bold)
+	hunk := color.New(color.FgCyan)
+	removed := color.New(color.FgRed)
+	added := color.New(color.FgGreen)
+
+	if result.Commit.SHA != "" {
+		util.Info("Target commit %s (%s)\n", result.Commit.SHA, result.Commit.Message)
+	}
+
+	for _, service := range result.Services {
+		header.Printf("--- live/%s/%s\n", service.Kind, service.Service)
+		header.Printf("+++ target/%s/%s (%s)\n", service.Kind, service.Service, service.Action)
+
+		for _, field := range service.Fields {
+			hunk.Printf("@@ %s @@\n", field.Field)
+
+			for _, line := range diffLines(field) {
+				switch line[0] {
+				case '-':
+					removed.Println(line)
+				case '+':
+					added.Println(line)
+				default:
+					fmt.Println(line)
+				}
+			}
+		}
+	}
+}
+
+// diffLines merges the (sorted) live and target lines
+// into unified diff lines prefixed with " ", "-" or "+"
+func diffLines(field application.FieldDiff) []string {
+	var lines []string
+
+	i, j := 0, 0
+	for i < len(field.Live) || j < len(field.Target) {
+		switch {
+		case j >= len(field.Target) || (i < len(field.Live) && field.Live[i] < field.Target[j]):
+			lines = append(lines, "-"+field.Live[i])
+			i++
+		case i >= len(field.Live) || field.Live[i] > field.Target[j]:
+			lines = append(lines, "+"+field.Target[j])
+			j++
+		default:
+			lines = append(lines, " "+field.Live[i])
+			i++
+			j++
+		}
+	}
+
+	return lines
+}
@@ -30,41 +30,49 @@ import (
 )
 
 func GetDetailsAboutApplication(_ *cobra.Command, args []string) error {
-	appName := args[0]
+	app, err := getApplication(args[0])
+	if err != nil {
+		return err
+	}
 
-	req, client, err := server.HTTPRequestWithBearerToken(http.MethodGet, fmt.Sprintf("%s/api/apps/%s", util.GetServer(), appName), nil, false)
+	bytes, err := json.MarshalIndent(app, "", "  ")
 	if err != nil {
 		return err
 	}
 
+	fmt.Println(string(bytes))
+	return nil
+}
+
+// getApplication returns the application from the server
+func getApplication(appName string) (application.Application, error) {
+	req, client, err := server.HTTPRequestWithBearerToken(http.MethodGet, fmt.Sprintf("%s/api/apps/%s", util.GetServer(), appName), nil, false)
+	if err != nil {
+		return application.Application{}, err
+	}
+
 	res, err := client.Do(req)
 	if err != nil {
-		return err
+		return application.Application{}, err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode == http.StatusUnauthorized {
-		return server.ReadAuthError(res.Body)
+		return application.Application{}, server.ReadAuthError(res.Body)
 	}
 
 	if res.StatusCode != http.StatusOK {
 		var resPayload api.GlobalResponse
 		if err := json.NewDecoder(res.Body).Decode(&resPayload); err != nil {
-			return err
+			return application.Application{}, err
 		}
-		return errors.New(resPayload.Message)
+		return application.Application{}, errors.New(resPayload.Message)
 	}
 
 	var resDada application.Application
 	if err := json.NewDecoder(res.Body).Decode(&resDada); err != nil {
-		return err
-	}
-
-	bytes, err := json.MarshalIndent(resDada, "", "  ")
-	if err != nil {
-		return err
+		return application.Application{}, err
 	}
 
-	fmt.Println(string(bytes))
-	return nil
+	return resDada, nil
 }
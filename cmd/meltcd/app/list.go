@@ -49,11 +49,11 @@ func GetAllApplications(_ *cobra.Command, _ []string) error {
 		return err
 	}
 
-	table := table.New("S.NO", "Name", "Health", "Last Synced At", "Created At", "Updated At")
+	table := table.New("S.NO", "Name", "Health", "Sync", "Last Synced At", "Created At", "Updated At")
 	table.WithHeaderFormatter(util.HeaderFmt).WithFirstColumnFormatter(util.ColumnFmt)
 
 	for _, v := range resPayload.Data {
-		table.AddRow(v.ID, v.Name, v.Health, util.GetSinceTime(v.LastSyncedAt), util.GetSinceTime(v.UpdatedAT), util.GetSinceTime(v.CreatedAt))
+		table.AddRow(v.ID, v.Name, v.Health, v.SyncStatus, util.GetSinceTime(v.LastSyncedAt), util.GetSinceTime(v.UpdatedAT), util.GetSinceTime(v.CreatedAt))
 	}
 
 	table.Print()
@@ -0,0 +1,82 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/meltred/meltcd/internal/core/application"
+	"github.com/meltred/meltcd/server"
+	api "github.com/meltred/meltcd/server/api/app"
+	"github.com/meltred/meltcd/util"
+	"github.com/spf13/cobra"
+)
+
+func RollbackApplication(_ *cobra.Command, args []string) error {
+	appName := args[0]
+
+	var payload api.RollbackRequest
+	if len(args) == 2 {
+		revision, err := strconv.Atoi(args[1])
+		if err != nil || revision <= 0 {
+			return fmt.Errorf("invalid revision %q, see the revisions with: meltcd app history %s", args[1], appName)
+		}
+		payload.Revision = revision
+	}
+
+	body := new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(payload); err != nil {
+		return err
+	}
+
+	req, client, err := server.HTTPRequestWithBearerToken(http.MethodPost, fmt.Sprintf("%s/api/apps/%s/rollback", util.GetServer(), appName), body, true)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return server.ReadAuthError(res.Body)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		var resPayload api.GlobalResponse
+		if err := json.NewDecoder(res.Body).Decode(&resPayload); err != nil {
+			return err
+		}
+		return errors.New(resPayload.Message)
+	}
+
+	var rev application.Revision
+	if err := json.NewDecoder(res.Body).Decode(&rev); err != nil {
+		return err
+	}
+
+	util.Info("Rolling back to revision %d (commit %s)", rev.ID, shortSHA(rev.Commit.SHA))
+	util.Info("Auto sync is paused, sync the application with: meltcd app refresh %s to deploy git and enable it again", appName)
+	return nil
+}
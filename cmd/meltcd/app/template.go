@@ -0,0 +1,64 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/meltred/meltcd/internal/core/render"
+	"github.com/meltred/meltcd/spec"
+	"github.com/spf13/cobra"
+)
+
+// RenderTemplate renders a local template directory (like a template source of an application)
+// and prints the service file, so the output can be reviewed before it is pushed
+func RenderTemplate(cmd *cobra.Command, args []string) error {
+	dir := args[0]
+
+	name, _ := cmd.Flags().GetString("name")
+	valueFiles, _ := cmd.Flags().GetStringArray("values")
+	set, _ := cmd.Flags().GetStringArray("set")
+
+	values, err := parseParams(set)
+	if err != nil {
+		return err
+	}
+
+	files := os.DirFS(dir)
+	rendered, err := render.Template(files, ".", render.TemplateOptions{
+		AppName:    name,
+		ValueFiles: valueFiles,
+		Set:        values,
+	})
+	if err != nil {
+		return err
+	}
+
+	// the rendered output must be a valid service file
+	swarmSpec, err := spec.Load(rendered, files, ".", nil)
+	if err != nil {
+		return fmt.Errorf("rendered service file is not valid: %w", err)
+	}
+
+	for _, w := range swarmSpec.Warnings() {
+		fmt.Fprintln(os.Stderr, "warning:", w)
+	}
+
+	fmt.Print(string(rendered))
+	return nil
+}
@@ -36,6 +36,24 @@ func UpdateExistingApplication(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	// the flags have defaults, so the current sync policy and self heal are kept when they
+	// are not set, otherwise an update would switch a manual application back to automated
+	syncPolicyChanged := cmd.Flags().Changed("sync-policy")
+	selfHealChanged := cmd.Flags().Changed("self-heal")
+	if len(args) != 0 && (!syncPolicyChanged || !selfHealChanged) {
+		current, err := getApplication(spec.Name)
+		if err != nil {
+			return err
+		}
+
+		if !syncPolicyChanged {
+			spec.SyncPolicy = current.SyncPolicy
+		}
+		if !selfHealChanged {
+			spec.SelfHeal = current.SelfHeal
+		}
+	}
+
 	app := application.New(spec)
 
 	buf := new(bytes.Buffer)
@@ -84,10 +84,29 @@ func NewCLI() *cobra.Command {
 	}
 
 	appCreateCmd.Flags().String("repo", "", "The git repository where the service file is hosted")
-	appCreateCmd.Flags().String("revision", "HEAD", "The git repository revision")
-	appCreateCmd.Flags().String("path", "", "The path to service file")
+	appCreateCmd.Flags().String("revision", "HEAD", "The git revision: branch, tag, commit sha or semver range on tags (like \"v1.2.*\")")
+	appCreateCmd.Flags().StringArray("path", nil, "The path to service file or to a directory with compose.yaml, repeat it to merge multiple files in order")
 	appCreateCmd.Flags().String("refresh", "3m0s", "The refresh time for sync")
 	appCreateCmd.Flags().String("file", "", "Application schema file")
+	appCreateCmd.Flags().String("sync-policy", "automated", "When to apply changes: automated (on every refresh) or manual (only with meltcd app sync)")
+	appCreateCmd.Flags().Bool("self-heal", true, "Revert changes done to the services outside of meltcd")
+	appCreateCmd.Flags().Bool("prune", false, "Remove services, networks, configs and secrets which are no longer in the service file")
+	appCreateCmd.Flags().Bool("prune-volumes", false, "Also remove volumes which are no longer in the service file (with --prune)")
+	appCreateCmd.Flags().Bool("prune-dry-run", false, "Only report what would be pruned (with --prune)")
+	appCreateCmd.Flags().StringArray("param", nil, "Value of a variable in the service file as KEY=VALUE (can be repeated)")
+	appCreateCmd.Flags().Bool("template", false, "The path is a directory of templates (templates/*.yaml) rendered with values.yaml")
+	appCreateCmd.Flags().StringArray("values", nil, "Values file of the template, relative to the path (can be repeated)")
+	appCreateCmd.Flags().StringArray("set", nil, "Value of the template as KEY=VALUE, like image.tag=1.4.2 (can be repeated)")
+	appCreateCmd.Flags().Bool("overlay", false, "The path is an overlay directory (with overlay.yaml) which patches a base")
+	appCreateCmd.Flags().StringArray("image", nil, "Image of the overlay as NAME=IMAGE[:TAG] or NAME:TAG (can be repeated)")
+	appCreateCmd.Flags().Bool("jsonnet", false, "The path is a jsonnet file which evaluates to the service file")
+	appCreateCmd.Flags().StringArray("ext-str", nil, "External variable of the jsonnet file as KEY=VALUE (can be repeated)")
+	appCreateCmd.Flags().StringArray("ext-code", nil, "External variable of the jsonnet file as KEY=CODE (can be repeated)")
+	appCreateCmd.Flags().StringArray("tla-str", nil, "Top-level argument of the jsonnet file as KEY=VALUE (can be repeated)")
+	appCreateCmd.Flags().StringArray("tla-code", nil, "Top-level argument of the jsonnet file as KEY=CODE (can be repeated)")
+	appCreateCmd.Flags().StringArray("jpath", nil, "Directory of the repository searched for jsonnet imports (can be repeated)")
+	appCreateCmd.Flags().Bool("apps", false, "The path is a directory of application files, the applications are created, updated and deleted to match it")
+	appCreateCmd.Flags().Bool("recurse", false, "Include the application files in subdirectories (with --apps)")
 
 	appUpdateCmd := &cobra.Command{
 		Use:   "update",
@@ -97,10 +116,29 @@ func NewCLI() *cobra.Command {
 	}
 
 	appUpdateCmd.Flags().String("repo", "", "The git repository where the service file is hosted")
-	appUpdateCmd.Flags().String("revision", "HEAD", "The git repository revision")
-	appUpdateCmd.Flags().String("path", "", "The path to service file")
+	appUpdateCmd.Flags().String("revision", "HEAD", "The git revision: branch, tag, commit sha or semver range on tags (like \"v1.2.*\")")
+	appUpdateCmd.Flags().StringArray("path", nil, "The path to service file or to a directory with compose.yaml, repeat it to merge multiple files in order")
 	appUpdateCmd.Flags().String("refresh", "3m0s", "The refresh time for sync")
 	appUpdateCmd.Flags().String("file", "", "Application schema file")
+	appUpdateCmd.Flags().String("sync-policy", "automated", "When to apply changes: automated (on every refresh) or manual (only with meltcd app sync), the current policy is kept when not set")
+	appUpdateCmd.Flags().Bool("self-heal", true, "Revert changes done to the services outside of meltcd, the current setting is kept when not set")
+	appUpdateCmd.Flags().Bool("prune", false, "Remove services, networks, configs and secrets which are no longer in the service file")
+	appUpdateCmd.Flags().Bool("prune-volumes", false, "Also remove volumes which are no longer in the service file (with --prune)")
+	appUpdateCmd.Flags().Bool("prune-dry-run", false, "Only report what would be pruned (with --prune)")
+	appUpdateCmd.Flags().StringArray("param", nil, "Value of a variable in the service file as KEY=VALUE (can be repeated)")
+	appUpdateCmd.Flags().Bool("template", false, "The path is a directory of templates (templates/*.yaml) rendered with values.yaml")
+	appUpdateCmd.Flags().StringArray("values", nil, "Values file of the template, relative to the path (can be repeated)")
+	appUpdateCmd.Flags().StringArray("set", nil, "Value of the template as KEY=VALUE, like image.tag=1.4.2 (can be repeated)")
+	appUpdateCmd.Flags().Bool("overlay", false, "The path is an overlay directory (with overlay.yaml) which patches a base")
+	appUpdateCmd.Flags().StringArray("image", nil, "Image of the overlay as NAME=IMAGE[:TAG] or NAME:TAG (can be repeated)")
+	appUpdateCmd.Flags().Bool("jsonnet", false, "The path is a jsonnet file which evaluates to the service file")
+	appUpdateCmd.Flags().StringArray("ext-str", nil, "External variable of the jsonnet file as KEY=VALUE (can be repeated)")
+	appUpdateCmd.Flags().StringArray("ext-code", nil, "External variable of the jsonnet file as KEY=CODE (can be repeated)")
+	appUpdateCmd.Flags().StringArray("tla-str", nil, "Top-level argument of the jsonnet file as KEY=VALUE (can be repeated)")
+	appUpdateCmd.Flags().StringArray("tla-code", nil, "Top-level argument of the jsonnet file as KEY=CODE (can be repeated)")
+	appUpdateCmd.Flags().StringArray("jpath", nil, "Directory of the repository searched for jsonnet imports (can be repeated)")
+	appUpdateCmd.Flags().Bool("apps", false, "The path is a directory of application files, the applications are created, updated and deleted to match it")
+	appUpdateCmd.Flags().Bool("recurse", false, "Include the application files in subdirectories (with --apps)")
 
 	appGetCmd := &cobra.Command{
 		Use:     "get",
@@ -142,6 +180,41 @@ func NewCLI() *cobra.Command {
 		RunE:    app.RecreateApplication,
 	}
 
+	appDiffCmd := &cobra.Command{
+		Use:   "diff APP_NAME",
+		Short: "Show what will change in the cluster when the application is synced",
+		Args:  cobra.ExactArgs(1),
+		RunE:  app.DiffApplication,
+	}
+
+	appDiffCmd.Flags().String("file", "", "Compare with a local service file instead of the one in git")
+	appDiffCmd.Flags().Bool("exit-code", false, "Exit with error if there are differences (useful in CI)")
+
+	appHistoryCmd := &cobra.Command{
+		Use:   "history APP_NAME",
+		Short: "Show the deployment history of the application",
+		Args:  cobra.ExactArgs(1),
+		RunE:  app.ApplicationHistory,
+	}
+
+	appRollbackCmd := &cobra.Command{
+		Use:   "rollback APP_NAME [REVISION]",
+		Short: "Re-apply a previous revision (the previous successful one by default) and pause auto sync",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE:  app.RollbackApplication,
+	}
+
+	appTemplateCmd := &cobra.Command{
+		Use:   "template DIR",
+		Short: "Render a local template directory and print the service file",
+		Args:  cobra.ExactArgs(1),
+		RunE:  app.RenderTemplate,
+	}
+
+	appTemplateCmd.Flags().String("name", "app", "Application name used in the templates (.App.Name)")
+	appTemplateCmd.Flags().StringArray("values", nil, "Values file, relative to the directory (can be repeated)")
+	appTemplateCmd.Flags().StringArray("set", nil, "Value as KEY=VALUE, like image.tag=1.4.2 (can be repeated)")
+
 	appCmd.AddCommand(appCreateCmd)
 	appCmd.AddCommand(appUpdateCmd)
 	appCmd.AddCommand(appGetCmd)
@@ -149,6 +222,10 @@ func NewCLI() *cobra.Command {
 	appCmd.AddCommand(appRefreshCmd)
 	appCmd.AddCommand(appRemoveCmd)
 	appCmd.AddCommand(appRecreateCmd)
+	appCmd.AddCommand(appDiffCmd)
+	appCmd.AddCommand(appHistoryCmd)
+	appCmd.AddCommand(appRollbackCmd)
+	appCmd.AddCommand(appTemplateCmd)
 
 	rootCmd.AddCommand(appCmd)
 
@@ -17,41 +17,92 @@ limitations under the License.
 package application
 
 import (
-	"bytes"
 	"context"
-	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
 	"strings"
 	"time"
 
 	"log/slog"
 
+	"github.com/meltred/meltcd/internal/core/gitcache"
 	"github.com/meltred/meltcd/internal/core/repository"
 	"github.com/meltred/meltcd/spec"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/swarm"
-	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
-	"github.com/go-git/go-billy/v5/memfs"
-	git "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
-	"github.com/go-git/go-git/v5/storage/memory"
-	"gopkg.in/yaml.v2"
 )
 
 type Application struct {
-	ID           uint32        `json:"id"`
-	Name         string        `json:"name"`
-	Source       Source        `json:"source"`
-	RefreshTimer string        `json:"refresh_timer"` // Timer to check for Sync format of "3m50s"
-	Health       Health        `json:"health"`
-	HealthStatus string        `json:"health_status"`
-	CreatedAt    time.Time     `json:"created_at"`
-	UpdatedAt    time.Time     `json:"updated_at"`
-	LastSyncedAt time.Time     `json:"last_synced_at"`
-	LiveState    string        `json:"-"`
-	SyncTrigger  chan SyncType `json:"-"`
+	ID                uint32            `json:"id"`
+	Name              string            `json:"name"`
+	Source            Source            `json:"source"`
+	RefreshTimer      string            `json:"refresh_timer"` // Timer to check for Sync format of "3m50s"
+	Health            Health            `json:"health"`
+	HealthStatus      string            `json:"health_status"`
+	SyncStatus        SyncStatus        `json:"sync_status"`
+	LastSyncError     string            `json:"last_sync_error"`   // empty when the last sync succeeded
+	ResolvedRevision  string            `json:"resolved_revision"` // commit sha the targetRevision resolved to in the last sync
+	SyncedCommit      Commit            `json:"synced_commit"`     // commit the live state is synced to
+	SyncedParameters  map[string]string `json:"synced_parameters"` // parameters the live state is synced to
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+	LastSyncAttemptAt time.Time         `json:"last_sync_attempt_at"`
+	LastSyncedAt      time.Time         `json:"last_synced_at"` // last successful sync
+	SyncPolicy        SyncPolicy        `json:"sync_policy"`
+	SelfHeal          *bool             `json:"self_heal,omitempty"`
+	Prune             PrunePolicy       `json:"prune"`
+	Parameters        map[string]string `json:"parameters"` // values of the variables in the service file
+	PruneReport       PruneReport       `json:"prune_report"`
+	DeployedRevision  int               `json:"deployed_revision"`        // id of the last successful revision in the history
+	Warnings          []string          `json:"warnings"`                 // ignored fields of the service file and drift of the volumes
+	Parent            string            `json:"parent,omitempty"`         // app of apps which manages the application
+	RolledBackTo      int               `json:"rolled_back_to,omitempty"` // revision pinned by a rollback, auto sync is paused until an explicit sync
+	SyncTrigger       chan SyncType     `json:"-"`
+
+	requestedBy     string    // user who requested the pending sync
+	pendingRollback *Revision // revision applied by the pending RollbackSync
+
+	ctx     context.Context    // canceled by Stop
+	cancel  context.CancelFunc // stops the sync loop started by Start
+	stopped chan struct{}      // closed when the sync loop returns
+}
+
+// Commit is a git commit of the application source
+type Commit struct {
+	SHA       string    `json:"sha"`
+	Author    string    `json:"author"`
+	Timestamp time.Time `json:"timestamp"`
+	Message   string    `json:"message"`
+}
+
+// TargetState is the desired state of the application read from git
+type TargetState struct {
+	Spec   string
+	Commit Commit
+	Files  fs.FS  // files of the repository at the commit, nil if not available
+	Dir    string // directory of the service file in the repository
+
+	Parameters map[string]string // values of the variables in the service file
+}
+
+// warnings are the unsupported fields of the service file,
+// a service file which can not be parsed is reported by the sync
+func (t TargetState) warnings() []string {
+	swarmSpec, err := t.parse()
+	if err != nil {
+		return nil
+	}
+
+	return swarmSpec.Warnings()
+}
+
+// parse returns the service file of the target state
+func (t TargetState) parse() (spec.DockerSwarm, error) {
+	return spec.Load([]byte(t.Spec), t.Files, t.Dir, t.Parameters)
 }
 
 type Health int
@@ -81,15 +132,128 @@ func (h Health) ToString() string {
 type SyncType int
 
 const (
-	Synchronize SyncType = iota
-	UpdateSync
+	Synchronize   SyncType = iota // explicit sync, applied even with manual sync policy
+	UpdateSync                    // application settings are updated
+	ScheduledSync                 // refresh timer
+	RollbackSync                  // re-apply a previous revision from the history
+	WebhookSync                   // push to the git repository, follows the sync policy like the refresh timer
 )
 
+// Start runs the sync loop of the application in a goroutine until Stop is called
+func (app *Application) Start() {
+	app.SyncTrigger = make(chan SyncType, 1)
+	app.ctx, app.cancel = context.WithCancel(context.Background())
+	app.stopped = make(chan struct{})
+
+	go func(stopped chan struct{}) {
+		defer close(stopped)
+		app.Run()
+	}(app.stopped)
+}
+
+// Stop stops the sync loop and waits for the running sync to finish,
+// so the application does not change the cluster once it returns
+func (app *Application) Stop() {
+	if app.cancel == nil {
+		return
+	}
+
+	slog.Info("Stopping application", "name", app.Name)
+	app.cancel()
+	<-app.stopped
+}
+
+// done is closed when the application is stopped, it is nil (never closed)
+// when the sync loop is not started with Start
+func (app *Application) done() <-chan struct{} {
+	if app.ctx == nil {
+		return nil
+	}
+	return app.ctx.Done()
+}
+
+// RequestSync triggers a sync of the running application,
+// by is the user who requested it and is recorded in the history.
+// Nothing is synced when the application is stopped
+func (app *Application) RequestSync(trigger SyncType, by string) {
+	app.requestedBy = by
+	select {
+	case app.SyncTrigger <- trigger:
+	case <-app.done():
+	}
+}
+
+// TrySync is RequestSync without waiting, it returns false when a sync is
+// already pending (which will pick up the changes anyway) or the application is stopped
+func (app *Application) TrySync(trigger SyncType, by string) bool {
+	select {
+	case <-app.done():
+		return false
+	default:
+	}
+
+	if len(app.SyncTrigger) == cap(app.SyncTrigger) {
+		return false
+	}
+
+	app.requestedBy = by
+	select {
+	case app.SyncTrigger <- trigger:
+		return true
+	default:
+		return false
+	}
+}
+
+// Rollback re-applies a previous revision, auto sync is paused (the revision is pinned)
+// so that the rolled back state is not overwritten by the next refresh. The pause is kept
+// when the settings are updated, and ends with an explicit sync
+func (app *Application) Rollback(rev Revision, by string) {
+	app.RolledBackTo = rev.ID
+	app.pendingRollback = &rev
+	app.RequestSync(RollbackSync, by)
+}
+
+// triggeredBy describes what started the sync, it is recorded in the history
+func (app *Application) triggeredBy(trigger SyncType) string {
+	by := app.requestedBy
+	app.requestedBy = ""
+
+	var name string
+	switch trigger {
+	case Synchronize:
+		name = "manual sync"
+	case UpdateSync:
+		name = "application update"
+	case ScheduledSync:
+		name = "refresh timer"
+	case RollbackSync:
+		name = "rollback"
+		if app.pendingRollback != nil {
+			name = fmt.Sprintf("rollback to revision %d", app.pendingRollback.ID)
+		}
+	case WebhookSync:
+		if by != "" {
+			return "webhook from " + by
+		}
+		name = "webhook"
+	}
+
+	if by == "" {
+		return name
+	}
+	return name + " by " + by
+}
+
 func New(spec Spec) Application {
 	return Application{
 		Name:         spec.Name,
 		RefreshTimer: spec.RefreshTimer,
 		Source:       spec.Source,
+		SyncPolicy:   spec.SyncPolicy,
+		SelfHeal:     spec.SelfHeal,
+		Prune:        spec.Prune,
+		Parameters:   spec.Parameters,
 	}
 }
 
@@ -104,51 +268,147 @@ func (app *Application) Run() {
 	if err := updateTicker(app.RefreshTimer, ticker); err != nil {
 		slog.Error(err.Error())
 		app.Health = Suspended
+		app.syncFailed("invalid refresh timer", err)
 		return
 	}
 
 	slog.Info("Staring sync process")
 
-	for ; true; waitSync(ticker.C, app.SyncTrigger) {
+	for trigger, ok := ScheduledSync, true; ok; trigger, ok = waitSync(ticker.C, app.SyncTrigger, app.done()) {
+		app.syncStarted()
+		triggeredBy := app.triggeredBy(trigger)
+
+		if trigger == RollbackSync {
+			app.rollback(triggeredBy)
+			continue
+		}
+
+		// an explicit sync applies git again, so the rolled back revision is no longer pinned
+		if trigger == Synchronize {
+			app.RolledBackTo = 0
+		}
+
 		if err := updateTicker(app.RefreshTimer, ticker); err != nil {
 			slog.Error(err.Error())
 			app.Health = Degraded
+			app.syncFailed("invalid refresh timer", err)
 			continue
 		}
 
-		targetState, err := app.GetState()
+		if app.Source.Apps != nil {
+			app.syncApps(trigger)
+			continue
+		}
+
+		target, err := app.GetState()
 		if err != nil {
 			slog.Warn("Not able to get service", "repo", app.Source.RepoURL)
 			slog.Error(err.Error())
 			app.Health = Degraded
+			app.syncFailed("failed to get target state", err)
 			continue
 		}
 		slog.Info("got target state")
-		if app.SyncStatus(targetState) {
-			// TODO: Sync Status = Synched
+		warnings := append(target.warnings(), app.volumeDriftWarnings(target)...)
+		warnings = append(warnings, app.networkDriftWarnings(target)...)
+		if !slices.Equal(warnings, app.Warnings) {
+			for _, w := range warnings {
+				slog.Warn("Service file is not fully applied", "app_name", app.Name, "warning", w)
+			}
+			app.Warnings = warnings
+		}
+
+		diffs, err := app.Diff(target)
+		if err != nil {
+			slog.Error("Not able to compare live and target state", "error", err.Error())
+			app.Health = Degraded
+			app.syncFailed("failed to compare live and target state", err)
+			continue
+		}
+
+		// the prune report is refreshed on every sync, also when nothing is deployed
+		// (like in dry run, where the resources to remove do not need a sync)
+		app.PruneReport = app.pruneReport(diffs)
+
+		if !app.needsSync(diffs) {
 			slog.Info("Synched")
 			app.Health = Healthy
+			app.syncSucceeded(target.Commit, target.Parameters)
+			continue
+		}
+		slog.Info("liveState and Target state is out of sync. syncing now...", "services", len(diffs))
+
+		app.SyncStatus = OutOfSync
+		if !app.shouldApply(trigger, target) {
+			slog.Info("Not applying changes, waiting for an explicit sync", "app_name", app.Name, "sync_policy", app.SyncPolicy)
 			continue
 		}
-		slog.Info("liveState and Target state is out of sync. syncing now...")
 
-		// // TODO: Sync Status = Out of Sync
 		app.Health = Progressing
-		if err := app.Apply(targetState); err != nil {
+		if err := app.deploy(target, triggeredBy); err != nil {
 			app.Health = Degraded
+			app.syncFailed("failed to apply target state", err)
 			slog.Warn("Not able to apply targetState", "error", err.Error())
 			continue
 		}
 
 		app.Health = Healthy
+		app.syncSucceeded(target.Commit, target.Parameters)
 		slog.Info("Applied new changes")
 	}
 }
 
-func waitSync(ticker <-chan time.Time, syncTrigger <-chan SyncType) {
+// rollback applies the pending rollback revision, the application stays
+// out of sync with git until the next explicit sync
+func (app *Application) rollback(triggeredBy string) {
+	rev := app.pendingRollback
+	app.pendingRollback = nil
+	if rev == nil {
+		return
+	}
+
+	slog.Info("Rolling back", "app_name", app.Name, "revision", rev.ID, "commit", rev.Commit.SHA)
+
+	target, err := app.StateAt(rev.Commit, rev.Spec, rev.Parameters)
+	if err != nil {
+		app.Health = Degraded
+		app.syncFailed(fmt.Sprintf("failed to rollback to revision %d", rev.ID), err)
+		return
+	}
+
+	app.Health = Progressing
+	if err := app.deploy(target, triggeredBy); err != nil {
+		app.Health = Degraded
+		app.syncFailed(fmt.Sprintf("failed to rollback to revision %d", rev.ID), err)
+		slog.Warn("Not able to rollback", "error", err.Error())
+		return
+	}
+
+	app.Health = Healthy
+	app.SyncStatus = OutOfSync
+	app.LastSyncError = ""
+	app.SyncedCommit = rev.Commit
+	app.SyncedParameters = rev.Parameters
+	slog.Info("Rolled back", "app_name", app.Name, "revision", rev.ID)
+}
+
+// waitSync waits for the next sync, it returns false when the application is stopped
+// (also when a sync is triggered at the same time)
+func waitSync(ticker <-chan time.Time, syncTrigger <-chan SyncType, done <-chan struct{}) (SyncType, bool) {
+	var trigger SyncType
 	select {
+	case <-done:
+		return 0, false
 	case <-ticker:
-	case <-syncTrigger:
+		trigger = ScheduledSync
+	case trigger = <-syncTrigger:
+	}
+
+	select {
+	case <-done:
+		return 0, false
+	default:
+		return trigger, true
 	}
 }
 
@@ -163,65 +423,102 @@ func updateTicker(duration string, t *time.Ticker) error {
 	return nil
 }
 
-func (app *Application) GetState() (string, error) {
+func (app *Application) GetState() (TargetState, error) {
 	slog.Info("Getting service state from git repo", "repo", app.Source.RepoURL, "app_name", app.Name)
-	// TODO: not using targetRevision
 
-	// TODO: IMPROVEMENT
-	// Use Docker Volumes to clone repository
-	// and then only fetch & pull if already exists
-	// and check if specified path is modified then apply the changes
-	fs := memfs.New()
-	storage := memory.NewStorage()
-	// defer clear storage, i (kunal singh) think that when storage goes out-of-scope
-	// it is cleared
+	files, commit, err := app.checkout()
+	if err != nil {
+		return TargetState{}, err
+	}
 
-	username, password := repository.FindCreds(app.Source.RepoURL)
+	serviceFile, err := app.Source.serviceFile(app.Name, files)
+	if err != nil {
+		return TargetState{}, err
+	}
+
+	return TargetState{
+		Spec:       serviceFile,
+		Commit:     commit,
+		Files:      files,
+		Dir:        app.Source.dir(files),
+		Parameters: app.Parameters,
+	}, nil
+}
+
+// checkout returns the files of the repository at the target revision and its commit
+func (app *Application) checkout() (fs.FS, Commit, error) {
+	repo, err := app.fetch()
+	if err != nil {
+		return nil, Commit{}, err
+	}
+
+	hash, err := repo.Resolve(app.Source.TargetRevision)
+	if err != nil {
+		return nil, Commit{}, err
+	}
+	slog.Info("Resolved target revision", "revision", app.Source.TargetRevision, "commit", hash.String())
+	app.ResolvedRevision = hash.String()
+
+	commit, err := repo.Commit(hash)
+	if err != nil {
+		return nil, Commit{}, err
+	}
+
+	files, err := repo.FS(hash)
+	if err != nil {
+		return nil, Commit{}, err
+	}
+
+	return files, Commit{
+		SHA:       hash.String(),
+		Author:    commit.Author.String(),
+		Timestamp: commit.Author.When,
+		Message:   strings.TrimSpace(commit.Message),
+	}, nil
+}
+
+// StateAt returns the target state with the service file and parameters (like a revision
+// in the history) and the files of the repository at the commit
+func (app *Application) StateAt(commit Commit, serviceFile string, parameters map[string]string) (TargetState, error) {
+	target := TargetState{
+		Spec:       serviceFile,
+		Commit:     commit,
+		Dir:        app.Source.dir(nil),
+		Parameters: parameters,
+	}
 
-	// TODO: Improvement
-	// GET the name and commit also
-	// so that we can show it in the ui or something
-	ref := plumbing.HEAD
-	if app.Source.TargetRevision != "HEAD" {
-		ref = plumbing.NewBranchReferenceName(app.Source.TargetRevision)
-	}
-
-	_, err := git.Clone(storage, fs, &git.CloneOptions{
-		URL:           app.Source.RepoURL,
-		ReferenceName: ref,
-		SingleBranch:  true,
-		Depth:         1,
-		Auth: &http.BasicAuth{
-			Username: username,
-			Password: password,
-		},
-	})
-
-	// if errors.Is(err, git.ErrRepositoryAlreadyExists) {
-	// 	//  fetch & pull request
-	// 	// don't clone again
-	// 	slog.Info("Repo already exits", "repo", app.Source.RepoURL)
-	// 	slog.Error("Since the storage is not persistent, this error should not exist")
-	// } else
+	if commit.SHA == "" {
+		return target, nil
+	}
+
+	repo, err := app.fetch()
 	if err != nil {
-		return "", err
+		return TargetState{}, err
 	}
 
-	serviceFile, err := fs.Open(app.Source.Path)
+	files, err := repo.FS(plumbing.NewHash(commit.SHA))
 	if err != nil {
-		slog.Error("Path not found", "repo", app.Source.RepoURL, "path", app.Source.Path)
-		return "", err
+		return TargetState{}, fmt.Errorf("commit %s is not found in the repository: %w", commit.SHA, err)
 	}
-	defer serviceFile.Close()
+	target.Files = files
+	target.Dir = app.Source.dir(files)
 
-	// reading the service file content
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(serviceFile)
+	return target, nil
+}
+
+// fetch brings the cached clone of the application source up to date
+func (app *Application) fetch() (*gitcache.Repo, error) {
+	username, password := repository.FindCreds(app.Source.RepoURL)
 
-	return buf.String(), nil
+	repo := gitcache.Get(app.Source.RepoURL)
+	if err := repo.Fetch(app.Source.TargetRevision, username, password); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
 }
 
-func (app *Application) Apply(targetState string) error {
+func (app *Application) Apply(target TargetState) error {
 	slog.Info("Applying new targetState")
 	// TODO this client can be stored i app or new struct core
 	cli, err := client.NewClientWithOpts(client.FromEnv)
@@ -230,38 +527,21 @@ func (app *Application) Apply(targetState string) error {
 		return err
 	}
 
-	var swarmSpec spec.DockerSwarm
-	if err := yaml.Unmarshal([]byte(targetState), &swarmSpec); err != nil {
+	swarmSpec, err := target.parse()
+	if err != nil {
 		return err
 	}
 
-	// TODO use volOpts
-	// create volume
-	for volName, volOpts := range swarmSpec.Volumes {
-		labels := make(map[string]string)
-		for _, l := range volOpts.Labels {
-			tokens := strings.SplitN(l, "=", 2)
-			if len(tokens) != 2 {
-				return errors.New("invalid labels in volume")
-			}
-
-			labels[tokens[0]] = tokens[1]
-		}
-
-		cli.VolumeCreate(context.Background(), volume.CreateOptions{
-			Name:       volName,
-			Driver:     volOpts.Driver,
-			DriverOpts: volOpts.DriverOpts,
-			Labels:     labels, // TODO labels are not working
-		})
+	if _, err := app.volumes(cli, &swarmSpec, true); err != nil {
+		return err
 	}
 
-	networkID, err := createNetwork(cli, app.Name)
+	refs, err := app.resourceRefs(cli, &swarmSpec, true)
 	if err != nil {
 		return err
 	}
 
-	services, err := swarmSpec.GetServiceSpec(app.Name, networkID)
+	services, err := swarmSpec.GetServiceSpec(app.Name, refs)
 	if err != nil {
 		return err
 	}
@@ -316,7 +596,6 @@ func (app *Application) Apply(targetState string) error {
 				slog.Warn("New Service update give warnings", "warnings", res.Warnings)
 			}
 
-			app.LastSyncedAt = time.Now()
 			continue
 		}
 
@@ -333,22 +612,17 @@ func (app *Application) Apply(targetState string) error {
 		if len(res.Warnings) != 0 {
 			slog.Warn("New Service Create give warnings", "warnings", res.Warnings)
 		}
+	}
 
-		app.LastSyncedAt = time.Now()
+	if app.Prune.Enabled {
+		if err := app.prune(cli, &swarmSpec, services); err != nil {
+			return err
+		}
 	}
 
-	app.LiveState = targetState
 	return nil
 }
 
-// SyncStatus Check if LiveState = TargetState
-//
-// Whether or not the live state matches the target state.
-// Is the deployed application the same as Git says it should be?
-func (app *Application) SyncStatus(targetState string) bool {
-	return app.LiveState == targetState
-}
-
 func checkServiceAlreadyExist(serviceName string, allServices *[]swarm.Service) (swarm.Service, bool) {
 	for _, svc := range *allServices {
 		if svc.Spec.Name == serviceName {
@@ -357,40 +631,3 @@ func checkServiceAlreadyExist(serviceName string, allServices *[]swarm.Service)
 	}
 	return swarm.Service{}, false
 }
-
-func createNetwork(cli *client.Client, appName string) (string, error) {
-	slog.Info("Creating network")
-	networkName := appName + "_default"
-
-	nets, err := cli.NetworkList(context.Background(), types.NetworkListOptions{})
-	if err != nil {
-		return "", err
-	}
-
-	for _, network := range nets {
-		if network.Name == networkName {
-			slog.Info("Network already exists")
-			return network.ID, nil
-		}
-	}
-
-	net, err := cli.NetworkCreate(context.Background(), networkName, types.NetworkCreate{
-		Scope: "swarm",
-		Labels: map[string]string{
-			"com.docker.stack.namespace": appName,
-		},
-		Driver: "overlay",
-	})
-
-	slog.Info("Created network", "id", net.ID)
-
-	if err != nil {
-		return "", err
-	}
-
-	if net.Warning != "" {
-		slog.Warn(net.Warning)
-	}
-
-	return net.ID, nil
-}
@@ -0,0 +1,45 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"testing"
+)
+
+func TestStop(t *testing.T) {
+	app := &Application{
+		Name:         "web",
+		RefreshTimer: "1h",
+		Source:       Source{RepoURL: "file://" + t.TempDir() + "/missing", TargetRevision: "HEAD", Path: "compose.yaml"},
+	}
+
+	app.Start()
+	app.Stop()
+	attempt := app.LastSyncAttemptAt
+
+	if app.TrySync(Synchronize, "admin") {
+		t.Error("sync is requested for a stopped application")
+	}
+
+	// the sync loop is not running, requesting a sync must not wait for it
+	app.RequestSync(Synchronize, "admin")
+	app.Stop()
+
+	if !app.LastSyncAttemptAt.Equal(attempt) {
+		t.Error("stopped application is synced")
+	}
+}
@@ -0,0 +1,624 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"log/slog"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+)
+
+type DiffAction string
+
+const (
+	DiffCreate DiffAction = "create"
+	DiffUpdate DiffAction = "update"
+	DiffRemove DiffAction = "remove" // only when pruning is enabled
+)
+
+// ServiceDiff is the difference between a service in the target state and the running service,
+// networks, configs, secrets and volumes are only part of the diff when they are going to be pruned
+type ServiceDiff struct {
+	Kind    string      `json:"kind"` // service, network, config, secret or volume
+	Service string      `json:"service"`
+	Action  DiffAction  `json:"action"`
+	Fields  []FieldDiff `json:"fields"`
+
+	id string // docker id of the resource to remove
+}
+
+// FieldDiff is a field of the service spec which differs between the live and target state,
+// values are normalized into sorted lines so that they can be compared and shown as a diff
+type FieldDiff struct {
+	Field  string   `json:"field"`
+	Live   []string `json:"live"`
+	Target []string `json:"target"`
+}
+
+// serviceFields are the fields of swarm.ServiceSpec compared by Diff
+var serviceFields = []struct {
+	name  string
+	lines func(swarm.ServiceSpec) []string
+	// isDefault tells if the live value is the default of swarm, which docker may
+	// report when the target does not set the field, so it is not a change
+	isDefault func(swarm.ServiceSpec) bool
+}{
+	{"image", imageLines, nil},
+	{"env", envLines, nil},
+	{"mounts", mountLines, nil},
+	{"ports", portLines, nil},
+	{"replicas", replicaLines, nil},
+	{"networks", networkLines, nil},
+	{"labels", labelLines, nil},
+	{"placement", placementLines, nil},
+	{"command", commandLines, nil},
+	{"container", containerLines, nil},
+	{"container_labels", containerLabelLines, nil},
+	{"healthcheck", healthcheckLines, nil},
+	{"logging", loggingLines, nil},
+	{"configs", configLines, nil},
+	{"secrets", secretLines, nil},
+	{"resources", resourceLines, nil},
+	{"restart_policy", restartPolicyLines, defaultRestartPolicy},
+	{"update_config", updateConfigLines(updateConfig), defaultUpdateConfig(updateConfig)},
+	{"rollback_config", updateConfigLines(rollbackConfig), defaultUpdateConfig(rollbackConfig)},
+}
+
+// Diff compares the services of the target state with the running services (field by field),
+// services which are already in sync are not part of the result
+func (app *Application) Diff(target TargetState) ([]ServiceDiff, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		slog.Error("Not able to create a new docker client")
+		return nil, err
+	}
+	defer cli.Close()
+
+	swarmSpec, err := target.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	// the networks, configs and secrets are not created here,
+	// if they do not exist yet the services will be different anyway
+	refs, err := app.resourceRefs(cli, &swarmSpec, false)
+	if err != nil {
+		return nil, err
+	}
+
+	services, err := swarmSpec.GetServiceSpec(app.Name, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []ServiceDiff
+	for _, service := range services {
+		running, _, err := cli.ServiceInspectWithRaw(context.Background(), service.Name, types.ServiceInspectOptions{})
+		if client.IsErrNotFound(err) {
+			diffs = append(diffs, ServiceDiff{
+				Kind:    "service",
+				Service: service.Name,
+				Action:  DiffCreate,
+				Fields:  diffServiceSpec(swarm.ServiceSpec{}, service),
+			})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if fields := diffServiceSpec(running.Spec, service); len(fields) != 0 {
+			diffs = append(diffs, ServiceDiff{
+				Kind:    "service",
+				Service: service.Name,
+				Action:  DiffUpdate,
+				Fields:  fields,
+			})
+		}
+	}
+
+	if app.Prune.Enabled {
+		candidates, err := app.pruneCandidates(cli, &swarmSpec, services)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range candidates {
+			removal := ServiceDiff{
+				Kind:    r.Kind,
+				Service: r.Name,
+				Action:  DiffRemove,
+				id:      r.ID,
+			}
+
+			if r.Kind == "service" {
+				running, _, err := cli.ServiceInspectWithRaw(context.Background(), r.ID, types.ServiceInspectOptions{})
+				if err != nil {
+					return nil, err
+				}
+				removal.Fields = diffServiceSpec(running.Spec, swarm.ServiceSpec{})
+			}
+
+			diffs = append(diffs, removal)
+		}
+	}
+
+	// services first, then networks, configs, secrets and volumes
+	kindOrder := map[string]int{"service": 0, "network": 1, "config": 2, "secret": 3, "volume": 4}
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Kind != diffs[j].Kind {
+			return kindOrder[diffs[i].Kind] < kindOrder[diffs[j].Kind]
+		}
+		return diffs[i].Service < diffs[j].Service
+	})
+
+	return diffs, nil
+}
+
+// needsSync tells if applying the target state will change anything,
+// services which are only reported for removal (prune dry run) do not count
+func (app *Application) needsSync(diffs []ServiceDiff) bool {
+	for _, d := range diffs {
+		if d.Action != DiffRemove || !app.Prune.DryRun {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pruneReport is the prune report of the sync with the diffs, in dry run it has the resources
+// which would be removed, otherwise they are added when they are removed by the deployment
+func (app *Application) pruneReport(diffs []ServiceDiff) PruneReport {
+	if !app.Prune.Enabled {
+		return PruneReport{}
+	}
+
+	report := PruneReport{DryRun: app.Prune.DryRun, At: time.Now()}
+	if !app.Prune.DryRun {
+		return report
+	}
+
+	for _, d := range diffs {
+		if d.Action == DiffRemove {
+			report.Resources = append(report.Resources, PruneResource{Kind: d.Kind, Name: d.Service, ID: d.id})
+		}
+	}
+	return report
+}
+
+func diffServiceSpec(live, target swarm.ServiceSpec) []FieldDiff {
+	var fields []FieldDiff
+
+	for _, field := range serviceFields {
+		liveLines := field.lines(live)
+		targetLines := field.lines(target)
+
+		if field.isDefault != nil && len(targetLines) == 0 && field.isDefault(live) {
+			continue
+		}
+
+		// docker resolves the image digest ("image:tag@sha256:..."),
+		// which is not a change unless the target pins a digest itself
+		if field.name == "image" && len(targetLines) == 1 && !strings.Contains(targetLines[0], "@") {
+			liveLines = imageLines(withoutDigest(live))
+		}
+
+		if !slices.Equal(liveLines, targetLines) {
+			fields = append(fields, FieldDiff{
+				Field:  field.name,
+				Live:   liveLines,
+				Target: targetLines,
+			})
+		}
+	}
+
+	return fields
+}
+
+func containerSpec(s swarm.ServiceSpec) swarm.ContainerSpec {
+	if s.TaskTemplate.ContainerSpec == nil {
+		return swarm.ContainerSpec{}
+	}
+	return *s.TaskTemplate.ContainerSpec
+}
+
+func withoutDigest(s swarm.ServiceSpec) swarm.ServiceSpec {
+	if s.TaskTemplate.ContainerSpec == nil {
+		return s
+	}
+
+	c := *s.TaskTemplate.ContainerSpec
+	c.Image, _, _ = strings.Cut(c.Image, "@")
+	s.TaskTemplate.ContainerSpec = &c
+
+	return s
+}
+
+func imageLines(s swarm.ServiceSpec) []string {
+	image := containerSpec(s).Image
+	if image == "" {
+		return nil
+	}
+	return []string{image}
+}
+
+func envLines(s swarm.ServiceSpec) []string {
+	return sortedLines(containerSpec(s).Env)
+}
+
+func mountLines(s swarm.ServiceSpec) []string {
+	var lines []string
+	for _, m := range containerSpec(s).Mounts {
+		line := fmt.Sprintf("%s:%s:%s", m.Type, m.Source, m.Target)
+		if m.ReadOnly {
+			line += ":ro"
+		}
+		if options := mountOptions(m); len(options) != 0 {
+			line += " (" + strings.Join(options, " ") + ")"
+		}
+		lines = append(lines, line)
+	}
+	return sortedLines(lines)
+}
+
+// mountOptions are the volume, bind and tmpfs options of the mount,
+// options which are not set are left out
+func mountOptions(m mount.Mount) []string {
+	var options []string
+	if v := m.VolumeOptions; v != nil {
+		if v.NoCopy {
+			options = append(options, "nocopy")
+		}
+		if v.DriverConfig != nil {
+			if v.DriverConfig.Name != "" {
+				options = append(options, "driver="+v.DriverConfig.Name)
+			}
+			for k, value := range v.DriverConfig.Options {
+				options = append(options, "driver_opt="+k+"="+value)
+			}
+		}
+		for k, value := range v.Labels {
+			options = append(options, "label="+k+"="+value)
+		}
+	}
+	if b := m.BindOptions; b != nil {
+		if b.Propagation != "" {
+			options = append(options, "propagation="+string(b.Propagation))
+		}
+		if b.CreateMountpoint {
+			options = append(options, "create_host_path")
+		}
+	}
+	if t := m.TmpfsOptions; t != nil {
+		if t.SizeBytes != 0 {
+			options = append(options, fmt.Sprintf("size=%d", t.SizeBytes))
+		}
+		if t.Mode != 0 {
+			options = append(options, fmt.Sprintf("mode=%#o", t.Mode))
+		}
+	}
+	return sortedLines(options)
+}
+
+func portLines(s swarm.ServiceSpec) []string {
+	if s.EndpointSpec == nil {
+		return nil
+	}
+
+	var lines []string
+	for _, p := range s.EndpointSpec.Ports {
+		protocol := p.Protocol
+		if protocol == "" {
+			protocol = swarm.PortConfigProtocolTCP
+		}
+		mode := p.PublishMode
+		if mode == "" {
+			mode = swarm.PortConfigPublishModeIngress
+		}
+		lines = append(lines, fmt.Sprintf("%d:%d/%s (%s)", p.PublishedPort, p.TargetPort, protocol, mode))
+	}
+	return sortedLines(lines)
+}
+
+func replicaLines(s swarm.ServiceSpec) []string {
+	switch {
+	case s.Mode.Global != nil:
+		return []string{"global"}
+	case s.Mode.Replicated != nil && s.Mode.Replicated.Replicas != nil:
+		return []string{fmt.Sprintf("replicated %d", *s.Mode.Replicated.Replicas)}
+	case s.TaskTemplate.ContainerSpec == nil:
+		// service does not exist
+		return nil
+	}
+
+	// docker defaults to a single replica
+	return []string{"replicated 1"}
+}
+
+func networkLines(s swarm.ServiceSpec) []string {
+	var lines []string
+	for _, n := range s.TaskTemplate.Networks {
+		aliases := slices.Clone(n.Aliases)
+		slices.Sort(aliases)
+		lines = append(lines, fmt.Sprintf("%s (aliases: %s)", n.Target, strings.Join(aliases, ", ")))
+	}
+	return sortedLines(lines)
+}
+
+func labelLines(s swarm.ServiceSpec) []string {
+	var lines []string
+	for k, v := range s.Labels {
+		lines = append(lines, k+"="+v)
+	}
+	return sortedLines(lines)
+}
+
+func placementLines(s swarm.ServiceSpec) []string {
+	p := s.TaskTemplate.Placement
+	if p == nil {
+		return nil
+	}
+
+	// platforms are not compared, docker sets them from the image
+	var lines []string
+	for _, c := range p.Constraints {
+		lines = append(lines, "constraint: "+c)
+	}
+	for _, pref := range p.Preferences {
+		if pref.Spread != nil {
+			lines = append(lines, "spread: "+pref.Spread.SpreadDescriptor)
+		}
+	}
+	if p.MaxReplicas != 0 {
+		lines = append(lines, fmt.Sprintf("max_replicas_per_node: %d", p.MaxReplicas))
+	}
+	return sortedLines(lines)
+}
+
+func commandLines(s swarm.ServiceSpec) []string {
+	c := containerSpec(s)
+
+	var lines []string
+	if len(c.Command) != 0 {
+		lines = append(lines, fmt.Sprintf("entrypoint: %q", c.Command))
+	}
+	if len(c.Args) != 0 {
+		lines = append(lines, fmt.Sprintf("command: %q", c.Args))
+	}
+	// the lines of a field are sorted, "command" comes before "entrypoint"
+	return sortedLines(lines)
+}
+
+// containerLines are the settings of the container which are a single value or a short list
+func containerLines(s swarm.ServiceSpec) []string {
+	c := containerSpec(s)
+
+	var lines []string
+	add := func(name string, value string) {
+		if value != "" {
+			lines = append(lines, name+": "+value)
+		}
+	}
+	list := func(name string, values []string) {
+		if len(values) != 0 {
+			add(name, strings.Join(sortedLines(values), ", "))
+		}
+	}
+
+	add("user", c.User)
+	add("working_dir", c.Dir)
+	add("hostname", c.Hostname)
+	add("stop_signal", c.StopSignal)
+	if c.StopGracePeriod != nil {
+		add("stop_grace_period", c.StopGracePeriod.String())
+	}
+	if c.Init != nil {
+		add("init", strconv.FormatBool(*c.Init))
+	}
+	if c.ReadOnly {
+		add("read_only", "true")
+	}
+	if c.TTY {
+		add("tty", "true")
+	}
+	if c.OpenStdin {
+		add("stdin_open", "true")
+	}
+	list("group_add", c.Groups)
+	list("cap_add", c.CapabilityAdd)
+	list("cap_drop", c.CapabilityDrop)
+	list("extra_hosts", c.Hosts)
+	for k, v := range c.Sysctls {
+		add("sysctl", k+"="+v)
+	}
+	for _, u := range c.Ulimits {
+		add("ulimit", fmt.Sprintf("%s=%d:%d", u.Name, u.Soft, u.Hard))
+	}
+	if c.DNSConfig != nil {
+		list("dns", c.DNSConfig.Nameservers)
+		list("dns_search", c.DNSConfig.Search)
+		list("dns_opt", c.DNSConfig.Options)
+	}
+
+	return sortedLines(lines)
+}
+
+func containerLabelLines(s swarm.ServiceSpec) []string {
+	var lines []string
+	for k, v := range containerSpec(s).Labels {
+		lines = append(lines, k+"="+v)
+	}
+	return sortedLines(lines)
+}
+
+func healthcheckLines(s swarm.ServiceSpec) []string {
+	h := containerSpec(s).Healthcheck
+	if h == nil {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("test=%q interval=%s timeout=%s start_period=%s retries=%d",
+		h.Test, h.Interval, h.Timeout, h.StartPeriod, h.Retries)}
+}
+
+func loggingLines(s swarm.ServiceSpec) []string {
+	d := s.TaskTemplate.LogDriver
+	if d == nil {
+		return nil
+	}
+
+	lines := []string{"driver: " + d.Name}
+	for k, v := range d.Options {
+		lines = append(lines, "option: "+k+"="+v)
+	}
+	return sortedLines(lines)
+}
+
+func configLines(s swarm.ServiceSpec) []string {
+	var lines []string
+	for _, c := range containerSpec(s).Configs {
+		line := c.ConfigName
+		if c.File != nil {
+			line += fmt.Sprintf(" -> %s (uid=%s gid=%s mode=%#o)", c.File.Name, c.File.UID, c.File.GID, c.File.Mode)
+		}
+		lines = append(lines, line)
+	}
+	return sortedLines(lines)
+}
+
+func secretLines(s swarm.ServiceSpec) []string {
+	var lines []string
+	for _, c := range containerSpec(s).Secrets {
+		line := c.SecretName
+		if c.File != nil {
+			line += fmt.Sprintf(" -> %s (uid=%s gid=%s mode=%#o)", c.File.Name, c.File.UID, c.File.GID, c.File.Mode)
+		}
+		lines = append(lines, line)
+	}
+	return sortedLines(lines)
+}
+
+func resourceLines(s swarm.ServiceSpec) []string {
+	r := s.TaskTemplate.Resources
+	if r == nil {
+		return nil
+	}
+
+	var lines []string
+	if r.Limits != nil && *r.Limits != (swarm.Limit{}) {
+		lines = append(lines, fmt.Sprintf("limits: cpus=%s memory=%d pids=%d", nanoCPUs(r.Limits.NanoCPUs), r.Limits.MemoryBytes, r.Limits.Pids))
+	}
+	if r.Reservations != nil && (r.Reservations.NanoCPUs != 0 || r.Reservations.MemoryBytes != 0) {
+		lines = append(lines, fmt.Sprintf("reservations: cpus=%s memory=%d", nanoCPUs(r.Reservations.NanoCPUs), r.Reservations.MemoryBytes))
+	}
+	return lines
+}
+
+func nanoCPUs(n int64) string {
+	return strconv.FormatFloat(float64(n)/1e9, 'f', -1, 64)
+}
+
+func restartPolicyLines(s swarm.ServiceSpec) []string {
+	p := s.TaskTemplate.RestartPolicy
+	if p == nil {
+		return nil
+	}
+
+	line := "condition=" + string(p.Condition)
+	if p.Delay != nil {
+		line += " delay=" + p.Delay.String()
+	}
+	if p.MaxAttempts != nil {
+		line += fmt.Sprintf(" max_attempts=%d", *p.MaxAttempts)
+	}
+	if p.Window != nil {
+		line += " window=" + p.Window.String()
+	}
+	return []string{line}
+}
+
+func updateConfigLines(config func(swarm.ServiceSpec) *swarm.UpdateConfig) func(swarm.ServiceSpec) []string {
+	return func(s swarm.ServiceSpec) []string {
+		c := config(s)
+		if c == nil {
+			return nil
+		}
+
+		return []string{fmt.Sprintf("parallelism=%d delay=%s failure_action=%s monitor=%s max_failure_ratio=%v order=%s",
+			c.Parallelism, c.Delay, c.FailureAction, c.Monitor, c.MaxFailureRatio, c.Order)}
+	}
+}
+
+func updateConfig(s swarm.ServiceSpec) *swarm.UpdateConfig {
+	return s.UpdateConfig
+}
+
+func rollbackConfig(s swarm.ServiceSpec) *swarm.UpdateConfig {
+	return s.RollbackConfig
+}
+
+// defaultRestartPolicy tells if the restart policy is the default of swarm (restart on any exit after 5s)
+func defaultRestartPolicy(s swarm.ServiceSpec) bool {
+	p := s.TaskTemplate.RestartPolicy
+	if p == nil {
+		return true
+	}
+
+	return (p.Condition == "" || p.Condition == swarm.RestartPolicyConditionAny) &&
+		(p.Delay == nil || *p.Delay == 5*time.Second) &&
+		(p.MaxAttempts == nil || *p.MaxAttempts == 0) &&
+		(p.Window == nil || *p.Window == 0)
+}
+
+// defaultUpdateConfig tells if the update (or rollback) config is the default of swarm
+// (one task at a time, pause on failure, stop the old task first)
+func defaultUpdateConfig(config func(swarm.ServiceSpec) *swarm.UpdateConfig) func(swarm.ServiceSpec) bool {
+	return func(s swarm.ServiceSpec) bool {
+		c := config(s)
+		if c == nil {
+			return true
+		}
+
+		return c.Parallelism == 1 && c.Delay == 0 &&
+			(c.FailureAction == "" || c.FailureAction == swarm.UpdateFailureActionPause) &&
+			(c.Monitor == 0 || c.Monitor == 5*time.Second) &&
+			c.MaxFailureRatio == 0 &&
+			(c.Order == "" || c.Order == swarm.UpdateOrderStopFirst)
+	}
+}
+
+func sortedLines(lines []string) []string {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	lines = slices.Clone(lines)
+	slices.Sort(lines)
+	return lines
+}
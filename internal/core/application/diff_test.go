@@ -0,0 +1,194 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+func TestDiffServiceSpec(t *testing.T) {
+	replicas := uint64(1)
+
+	target := swarm.ServiceSpec{
+		TaskTemplate: swarm.TaskSpec{
+			ContainerSpec: &swarm.ContainerSpec{
+				Image: "nginx:latest",
+				Env:   []string{"A=1", "B=2"},
+			},
+		},
+		EndpointSpec: &swarm.EndpointSpec{
+			Ports: []swarm.PortConfig{{TargetPort: 80, PublishedPort: 8080}},
+		},
+	}
+
+	live := swarm.ServiceSpec{
+		Mode: swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: &replicas}},
+		TaskTemplate: swarm.TaskSpec{
+			ContainerSpec: &swarm.ContainerSpec{
+				Image: "nginx:latest@sha256:4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac",
+				Env:   []string{"B=2", "A=1"},
+			},
+		},
+		EndpointSpec: &swarm.EndpointSpec{
+			Mode: swarm.ResolutionModeVIP,
+			Ports: []swarm.PortConfig{{
+				Protocol:      swarm.PortConfigProtocolTCP,
+				TargetPort:    80,
+				PublishedPort: 8080,
+				PublishMode:   swarm.PortConfigPublishModeIngress,
+			}},
+		},
+	}
+
+	if diff := diffServiceSpec(live, target); len(diff) != 0 {
+		t.Error("defaults filled by docker are reported as diff", diff)
+	}
+
+	live.TaskTemplate.ContainerSpec.Env = []string{"A=1", "B=3"}
+
+	diff := diffServiceSpec(live, target)
+	if len(diff) != 1 || diff[0].Field != "env" {
+		t.Error("changed environment variable is not reported", diff)
+	}
+}
+
+func TestDiffMountOptions(t *testing.T) {
+	withMount := func(m mount.Mount) swarm.ServiceSpec {
+		return swarm.ServiceSpec{
+			TaskTemplate: swarm.TaskSpec{ContainerSpec: &swarm.ContainerSpec{Image: "nginx", Mounts: []mount.Mount{m}}},
+		}
+	}
+
+	volume := mount.Mount{Type: mount.TypeVolume, Source: "app_data", Target: "/data"}
+	bind := mount.Mount{Type: mount.TypeBind, Source: "/srv/app", Target: "/app"}
+	tmpfs := mount.Mount{Type: mount.TypeTmpfs, Target: "/tmp"}
+
+	cases := []struct {
+		name         string
+		live, target mount.Mount
+	}{
+		{"nocopy", volume, mount.Mount{Type: volume.Type, Source: volume.Source, Target: volume.Target, VolumeOptions: &mount.VolumeOptions{NoCopy: true}}},
+		{"driver", volume, mount.Mount{Type: volume.Type, Source: volume.Source, Target: volume.Target, VolumeOptions: &mount.VolumeOptions{DriverConfig: &mount.Driver{Name: "local"}}}},
+		{"driver options",
+			mount.Mount{Type: volume.Type, Source: volume.Source, Target: volume.Target, VolumeOptions: &mount.VolumeOptions{DriverConfig: &mount.Driver{Name: "local", Options: map[string]string{"o": "addr=10.0.0.1"}}}},
+			mount.Mount{Type: volume.Type, Source: volume.Source, Target: volume.Target, VolumeOptions: &mount.VolumeOptions{DriverConfig: &mount.Driver{Name: "local", Options: map[string]string{"o": "addr=10.0.0.2"}}}}},
+		{"labels", volume, mount.Mount{Type: volume.Type, Source: volume.Source, Target: volume.Target, VolumeOptions: &mount.VolumeOptions{Labels: map[string]string{"backup": "daily"}}}},
+		{"propagation", bind, mount.Mount{Type: bind.Type, Source: bind.Source, Target: bind.Target, BindOptions: &mount.BindOptions{Propagation: mount.PropagationRShared}}},
+		{"create host path", bind, mount.Mount{Type: bind.Type, Source: bind.Source, Target: bind.Target, BindOptions: &mount.BindOptions{CreateMountpoint: true}}},
+		{"tmpfs size", tmpfs, mount.Mount{Type: tmpfs.Type, Target: tmpfs.Target, TmpfsOptions: &mount.TmpfsOptions{SizeBytes: 1 << 20}}},
+		{"tmpfs mode", tmpfs, mount.Mount{Type: tmpfs.Type, Target: tmpfs.Target, TmpfsOptions: &mount.TmpfsOptions{Mode: 0o700}}},
+	}
+
+	for _, c := range cases {
+		if diff := diffServiceSpec(withMount(c.target), withMount(c.target)); len(diff) != 0 {
+			t.Errorf("%s: same mounts are reported as diff %v", c.name, diff)
+		}
+
+		diff := diffServiceSpec(withMount(c.live), withMount(c.target))
+		if len(diff) != 1 || diff[0].Field != "mounts" {
+			t.Errorf("%s: changed mount option is not reported, diff = %v", c.name, diff)
+		}
+	}
+
+	// options which are not set are same as empty options
+	empty := mount.Mount{Type: bind.Type, Source: bind.Source, Target: bind.Target, BindOptions: &mount.BindOptions{}}
+	if diff := diffServiceSpec(withMount(empty), withMount(bind)); len(diff) != 0 {
+		t.Errorf("empty bind options are reported as diff %v", diff)
+	}
+}
+
+func TestCommandLines(t *testing.T) {
+	s := swarm.ServiceSpec{
+		TaskTemplate: swarm.TaskSpec{ContainerSpec: &swarm.ContainerSpec{
+			Command: []string{"/entrypoint.sh"},
+			Args:    []string{"serve"},
+		}},
+	}
+
+	// the cli diffs the lines of a field by walking them in order
+	if lines := commandLines(s); len(lines) != 2 || !slices.IsSorted(lines) {
+		t.Errorf("command lines are not sorted: %q", lines)
+	}
+}
+
+func TestDiffRemovedDeployFields(t *testing.T) {
+	delay := 5 * time.Second
+	attempts := uint64(0)
+
+	target := swarm.ServiceSpec{
+		TaskTemplate: swarm.TaskSpec{ContainerSpec: &swarm.ContainerSpec{Image: "nginx"}},
+	}
+
+	// defaults reported by docker for the fields the target does not set
+	live := target
+	live.TaskTemplate.RestartPolicy = &swarm.RestartPolicy{Condition: swarm.RestartPolicyConditionAny, Delay: &delay, MaxAttempts: &attempts}
+	live.UpdateConfig = &swarm.UpdateConfig{Parallelism: 1, FailureAction: swarm.UpdateFailureActionPause, Monitor: 5 * time.Second, Order: swarm.UpdateOrderStopFirst}
+	live.TaskTemplate.Resources = &swarm.ResourceRequirements{Limits: &swarm.Limit{}, Reservations: &swarm.Resources{}}
+
+	if diff := diffServiceSpec(live, target); len(diff) != 0 {
+		t.Error("defaults filled by docker are reported as diff", diff)
+	}
+
+	// the fields are removed from the service file
+	live.TaskTemplate.RestartPolicy = &swarm.RestartPolicy{Condition: swarm.RestartPolicyConditionOnFailure}
+	live.UpdateConfig = &swarm.UpdateConfig{Parallelism: 2, Order: swarm.UpdateOrderStartFirst}
+	live.TaskTemplate.Resources = &swarm.ResourceRequirements{Limits: &swarm.Limit{MemoryBytes: 1 << 20}}
+
+	fields := map[string]bool{}
+	for _, d := range diffServiceSpec(live, target) {
+		fields[d.Field] = true
+	}
+	for _, f := range []string{"restart_policy", "update_config", "resources"} {
+		if !fields[f] {
+			t.Errorf("removed %s is not reported, diff = %v", f, fields)
+		}
+	}
+}
+
+func TestPruneReport(t *testing.T) {
+	diffs := []ServiceDiff{
+		{Kind: "service", Service: "app_web", Action: DiffUpdate},
+		{Kind: "service", Service: "app_old", Action: DiffRemove, id: "s1"},
+		{Kind: "network", Service: "app_old_net", Action: DiffRemove, id: "n1"},
+	}
+
+	app := Application{Prune: PrunePolicy{Enabled: true, DryRun: true}}
+	report := app.pruneReport(diffs)
+	if !report.DryRun || len(report.Resources) != 2 || report.Resources[0] != (PruneResource{Kind: "service", Name: "app_old", ID: "s1"}) {
+		t.Errorf("dry run report = %+v", report)
+	}
+
+	// a stale report is cleared when nothing would be removed
+	if report := app.pruneReport(diffs[:1]); len(report.Resources) != 0 || report.At.IsZero() {
+		t.Errorf("report = %+v, want an empty report", report)
+	}
+
+	app.Prune.DryRun = false
+	if report := app.pruneReport(diffs); report.DryRun || len(report.Resources) != 0 {
+		t.Errorf("report = %+v, resources are added when they are removed", report)
+	}
+
+	app.Prune.Enabled = false
+	if report := app.pruneReport(diffs); !report.At.IsZero() {
+		t.Errorf("report = %+v, want no report when pruning is disabled", report)
+	}
+}
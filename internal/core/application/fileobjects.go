@@ -0,0 +1,176 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"log/slog"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+	"github.com/meltred/meltcd/spec"
+)
+
+// resourceRefs returns the docker networks, configs and secrets used by the services,
+// missing ones are created when create is true
+func (app *Application) resourceRefs(cli *client.Client, swarmSpec *spec.DockerSwarm, create bool) (spec.ResourceRefs, error) {
+	networks, _, err := app.networks(cli, swarmSpec, create)
+	if err != nil {
+		return spec.ResourceRefs{}, err
+	}
+
+	configs, err := app.configs(cli, swarmSpec, create)
+	if err != nil {
+		return spec.ResourceRefs{}, err
+	}
+
+	secrets, err := app.secrets(cli, swarmSpec, create)
+	if err != nil {
+		return spec.ResourceRefs{}, err
+	}
+
+	return spec.ResourceRefs{
+		Networks: networks,
+		Configs:  configs,
+		Secrets:  secrets,
+	}, nil
+}
+
+func (app *Application) configs(cli *client.Client, swarmSpec *spec.DockerSwarm, create bool) (map[string]spec.Ref, error) {
+	used, err := swarmSpec.UsedConfigs(app.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(used) == 0 {
+		return map[string]spec.Ref{}, nil
+	}
+
+	list, err := cli.ConfigList(context.Background(), types.ConfigListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	existing := map[string]string{}
+	for _, c := range list {
+		existing[c.Spec.Name] = c.ID
+	}
+
+	return app.fileObjectRefs("config", used, existing, create, func(data spec.FileObjectData) (string, error) {
+		res, err := cli.ConfigCreate(context.Background(), swarm.ConfigSpec{
+			Annotations: swarm.Annotations{Name: data.Name, Labels: data.Labels},
+			Data:        data.Data,
+		})
+		return res.ID, err
+	})
+}
+
+func (app *Application) secrets(cli *client.Client, swarmSpec *spec.DockerSwarm, create bool) (map[string]spec.Ref, error) {
+	used, err := swarmSpec.UsedSecrets(app.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(used) == 0 {
+		return map[string]spec.Ref{}, nil
+	}
+
+	list, err := cli.SecretList(context.Background(), types.SecretListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	existing := map[string]string{}
+	for _, s := range list {
+		existing[s.Spec.Name] = s.ID
+	}
+
+	return app.fileObjectRefs("secret", used, existing, create, func(data spec.FileObjectData) (string, error) {
+		res, err := cli.SecretCreate(context.Background(), swarm.SecretSpec{
+			Annotations: swarm.Annotations{Name: data.Name, Labels: data.Labels},
+			Data:        data.Data,
+		})
+		return res.ID, err
+	})
+}
+
+// fileObjectRefs finds the configs or secrets by name in existing (name to id), createFn creates a missing one,
+// the name has the hash of the content, so an existing one has the same content
+func (app *Application) fileObjectRefs(kind string, used map[string]spec.FileObjectData, existing map[string]string, create bool,
+	createFn func(spec.FileObjectData) (string, error),
+) (map[string]spec.Ref, error) {
+	refs := map[string]spec.Ref{}
+
+	for name, data := range used {
+		if id, ok := existing[data.Name]; ok {
+			refs[name] = spec.Ref{ID: id, Name: data.Name}
+			continue
+		}
+
+		if data.External {
+			return nil, fmt.Errorf("external %s %s is not found, it must be created before the application", kind, data.Name)
+		}
+
+		if !create {
+			refs[name] = spec.Ref{Name: data.Name}
+			continue
+		}
+
+		data.Labels[stackNamespaceLabel] = app.Name
+
+		slog.Info("Creating "+kind, "app_name", app.Name, "name", data.Name)
+		id, err := createFn(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s %s: %w", kind, data.Name, err)
+		}
+
+		refs[name] = spec.Ref{ID: id, Name: data.Name}
+	}
+
+	return refs, nil
+}
+
+// staleFileObjects returns the configs and secrets of the application which are not used by
+// the services anymore (like the previous version of a changed config), they are pruned
+func staleFileObjects(services []swarm.ServiceSpec, configs []swarm.Config, secrets []swarm.Secret) []PruneResource {
+	usedConfigs := map[string]bool{}
+	usedSecrets := map[string]bool{}
+
+	for _, service := range services {
+		for _, c := range containerSpec(service).Configs {
+			usedConfigs[c.ConfigName] = true
+		}
+		for _, s := range containerSpec(service).Secrets {
+			usedSecrets[s.SecretName] = true
+		}
+	}
+
+	var stale []PruneResource
+	for _, c := range configs {
+		if !usedConfigs[c.Spec.Name] {
+			stale = append(stale, PruneResource{Kind: "config", Name: c.Spec.Name, ID: c.ID})
+		}
+	}
+	for _, s := range secrets {
+		if !usedSecrets[s.Spec.Name] {
+			stale = append(stale, PruneResource{Kind: "secret", Name: s.Spec.Name, ID: s.ID})
+		}
+	}
+
+	return stale
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+)
+
+func TestStaleFileObjects(t *testing.T) {
+	services := []swarm.ServiceSpec{{
+		TaskTemplate: swarm.TaskSpec{ContainerSpec: &swarm.ContainerSpec{
+			Configs: []*swarm.ConfigReference{{ConfigName: "app_nginx-2b1f"}},
+			Secrets: []*swarm.SecretReference{{SecretName: "app_token-9c3e"}},
+		}},
+	}}
+
+	configs := []swarm.Config{
+		{ID: "c1", Spec: swarm.ConfigSpec{Annotations: swarm.Annotations{Name: "app_nginx-2b1f"}}},
+		{ID: "c2", Spec: swarm.ConfigSpec{Annotations: swarm.Annotations{Name: "app_nginx-7a0d"}}},
+	}
+	secrets := []swarm.Secret{
+		{ID: "s1", Spec: swarm.SecretSpec{Annotations: swarm.Annotations{Name: "app_token-9c3e"}}},
+		{ID: "s2", Spec: swarm.SecretSpec{Annotations: swarm.Annotations{Name: "app_attached"}}},
+	}
+
+	want := []PruneResource{
+		{Kind: "config", Name: "app_nginx-7a0d", ID: "c2"},
+		{Kind: "secret", Name: "app_attached", ID: "s2"},
+	}
+	if got := staleFileObjects(services, configs, secrets); !reflect.DeepEqual(got, want) {
+		t.Errorf("stale = %+v, want %+v", got, want)
+	}
+
+	// the unused configs and secrets are only removed by pruning, and reported in dry run
+	diffs := []ServiceDiff{
+		{Kind: "config", Service: "app_nginx-7a0d", Action: DiffRemove, id: "c2"},
+		{Kind: "secret", Service: "app_attached", Action: DiffRemove, id: "s2"},
+	}
+
+	app := Application{Prune: PrunePolicy{Enabled: true, DryRun: true}}
+	if report := app.pruneReport(diffs); len(report.Resources) != 2 || app.needsSync(diffs) {
+		t.Errorf("dry run report = %+v, needs sync = %v", report, app.needsSync(diffs))
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"reflect"
+	"strings"
+)
+
+// ChildReconciler creates, updates and deletes the child applications of the app of apps parent
+// to match the specs, it returns the changes (like "create web"). Nothing is changed when
+// apply is false, so the changes of a manual sync policy are only reported
+type ChildReconciler func(parent string, specs []Spec, apply bool) ([]string, error)
+
+// reconcileChildren is set by the registry (core package) with SetChildReconciler
+var reconcileChildren ChildReconciler
+
+// SetChildReconciler sets the reconciler of the child applications of the apps of apps
+func SetChildReconciler(r ChildReconciler) {
+	reconcileChildren = r
+}
+
+// Spec returns the settings of the application, like they are written in an application spec
+func (app *Application) Spec() Spec {
+	return Spec{
+		Name:         app.Name,
+		RefreshTimer: app.RefreshTimer,
+		Source:       app.Source,
+		SyncPolicy:   app.SyncPolicy,
+		SelfHeal:     app.SelfHeal,
+		Prune:        app.Prune,
+		Parameters:   app.Parameters,
+	}
+}
+
+// SameSpec tells if the application has the settings of the spec
+func (app *Application) SameSpec(spec Spec) bool {
+	return reflect.DeepEqual(app.Spec(), spec)
+}
+
+// syncApps syncs the app of apps, the child applications are changed
+// to match the application specs in the directory of the source
+func (app *Application) syncApps(trigger SyncType) {
+	if reconcileChildren == nil {
+		app.Health = Suspended
+		app.syncFailed("failed to sync applications", errors.New("app of apps is not supported"))
+		return
+	}
+
+	files, commit, err := app.checkout()
+	if err != nil {
+		slog.Error(err.Error())
+		app.Health = Degraded
+		app.syncFailed("failed to get target state", err)
+		return
+	}
+
+	specs, err := app.childSpecs(files)
+	if err != nil {
+		app.Health = Degraded
+		app.syncFailed("failed to read application specs", err)
+		return
+	}
+
+	changes, err := reconcileChildren(app.Name, specs, false)
+	if err != nil {
+		app.Health = Degraded
+		app.syncFailed("failed to compare applications", err)
+		return
+	}
+
+	if len(changes) == 0 {
+		app.Health = Healthy
+		app.syncSucceeded(commit, nil)
+		return
+	}
+	slog.Info("Applications are out of sync", "app_name", app.Name, "changes", changes)
+
+	app.SyncStatus = OutOfSync
+	if trigger != Synchronize && !app.autoSync() {
+		slog.Info("Not applying changes, waiting for an explicit sync", "app_name", app.Name, "sync_policy", app.SyncPolicy)
+		return
+	}
+
+	app.Health = Progressing
+	if _, err := reconcileChildren(app.Name, specs, true); err != nil {
+		app.Health = Degraded
+		app.syncFailed("failed to apply applications", err)
+		return
+	}
+
+	app.Health = Healthy
+	app.syncSucceeded(commit, nil)
+	slog.Info("Applied application changes", "app_name", app.Name, "changes", changes)
+}
+
+// childSpecs reads the application specs (.yaml, .yml and .json files) in the directory of
+// the source, the repository of the app of apps is used when a spec does not have one
+func (app *Application) childSpecs(files fs.FS) ([]Spec, error) {
+	dir := path.Clean(app.Source.Path)
+
+	var specs []Spec
+	err := fs.WalkDir(files, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if p != dir && (!app.Source.Apps.Recurse || strings.HasPrefix(d.Name(), ".")) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		switch path.Ext(p) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+
+		data, err := fs.ReadFile(files, p)
+		if err != nil {
+			return err
+		}
+
+		spec, err := ParseSpec(p, data)
+		if err != nil {
+			return fmt.Errorf("application spec %s: %w", p, err)
+		}
+
+		if spec.Source.RepoURL == "" {
+			spec.Source.RepoURL = app.Source.RepoURL
+			if spec.Source.TargetRevision == "" {
+				spec.Source.TargetRevision = app.Source.TargetRevision
+			}
+		}
+
+		specs = append(specs, spec)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return specs, nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestChildSpecs(t *testing.T) {
+	files := fstest.MapFS{
+		"apps/web.yaml": &fstest.MapFile{Data: []byte(`name: web
+refresh_timer: 3m
+source:
+  path: web/compose.yaml
+`)},
+		"apps/db.json":         &fstest.MapFile{Data: []byte(`{"name": "db", "source": {"repoURL": "https://github.com/meltred/db", "path": "compose.yaml"}}`)},
+		"apps/README.md":       &fstest.MapFile{Data: []byte("application specs")},
+		"apps/team/api.yml":    &fstest.MapFile{Data: []byte("name: api\nsource:\n  path: api\n")},
+		"apps/.hidden/x.yaml":  &fstest.MapFile{Data: []byte("name: x\n")},
+		"broken/invalid.yaml":  &fstest.MapFile{Data: []byte("name: [\n")},
+		"other/unrelated.yaml": &fstest.MapFile{Data: []byte("name: unrelated\n")},
+	}
+
+	app := Application{
+		Name: "apps",
+		Source: Source{
+			RepoURL:        "https://github.com/meltred/apps",
+			TargetRevision: "main",
+			Path:           "apps/",
+			Apps:           &AppsSource{},
+		},
+	}
+
+	specs, err := app.childSpecs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := map[string]Spec{}
+	for _, s := range specs {
+		names[s.Name] = s
+	}
+	if len(names) != 2 || names["web"].Name == "" || names["db"].Name == "" {
+		t.Fatalf("specs = %+v, want web and db", specs)
+	}
+	if web := names["web"].Source; web.RepoURL != app.Source.RepoURL || web.TargetRevision != "main" {
+		t.Errorf("web source = %+v, want the repository of the app of apps", web)
+	}
+	if db := names["db"].Source; db.RepoURL != "https://github.com/meltred/db" || db.TargetRevision != "" {
+		t.Errorf("db source = %+v, want its own repository", db)
+	}
+
+	app.Source.Apps.Recurse = true
+	specs, err = app.childSpecs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 3 {
+		t.Errorf("specs = %+v, want web, db and api (hidden directories are skipped)", specs)
+	}
+
+	app.Source.Path = "broken"
+	if _, err := app.childSpecs(files); err == nil || !strings.Contains(err.Error(), "broken/invalid.yaml") {
+		t.Errorf("expected error for invalid spec, got %v", err)
+	}
+
+	app.Source.Path = "missing"
+	if _, err := app.childSpecs(files); err == nil {
+		t.Error("expected error for missing directory")
+	}
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"log/slog"
+)
+
+// maxHistory is the number of revisions kept per application
+const maxHistory = 50
+
+const (
+	RevisionSucceeded = "succeeded"
+	RevisionFailed    = "failed"
+)
+
+var (
+	historyDir string
+	historyMu  sync.Mutex
+)
+
+// Revision is a deployment of the application
+type Revision struct {
+	ID          int               `json:"id"`
+	Commit      Commit            `json:"commit"`
+	Spec        string            `json:"spec"` // the service file which was applied
+	Parameters  map[string]string `json:"parameters,omitempty"`
+	TriggeredBy string            `json:"triggered_by"`
+	Result      string            `json:"result"` // succeeded or failed
+	Error       string            `json:"error"`
+	StartedAt   time.Time         `json:"started_at"`
+	FinishedAt  time.Time         `json:"finished_at"`
+}
+
+// SetupHistory sets the directory where the revision history
+// of every application is stored (one file per application)
+func SetupHistory(dir string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	historyMu.Lock()
+	historyDir = dir
+	historyMu.Unlock()
+
+	return nil
+}
+
+// History returns the revisions of the application, oldest first
+func History(appName string) ([]Revision, error) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	return readHistory(appName)
+}
+
+// FindRevision returns the revision with the id, when id is 0 it returns
+// the last successful revision deployed before the current one
+func FindRevision(appName string, id int, current int) (Revision, error) {
+	revisions, err := History(appName)
+	if err != nil {
+		return Revision{}, err
+	}
+
+	if id != 0 {
+		for _, rev := range revisions {
+			if rev.ID == id {
+				return rev, nil
+			}
+		}
+		return Revision{}, fmt.Errorf("revision %d not found", id)
+	}
+
+	// when the current revision is not known the latest successful one is the current
+	latest := current == 0
+	for i := len(revisions) - 1; i >= 0; i-- {
+		rev := revisions[i]
+		if rev.Result != RevisionSucceeded {
+			continue
+		}
+
+		if latest {
+			latest = false
+			continue
+		}
+
+		if current == 0 || rev.ID < current {
+			return rev, nil
+		}
+	}
+
+	return Revision{}, errors.New("no previous successful revision found")
+}
+
+// deploy applies the target state and records it in the history
+func (app *Application) deploy(target TargetState, triggeredBy string) error {
+	rev := Revision{
+		Commit:      target.Commit,
+		Spec:        target.Spec,
+		Parameters:  target.Parameters,
+		TriggeredBy: triggeredBy,
+		StartedAt:   time.Now(),
+	}
+
+	err := app.Apply(target)
+
+	rev.FinishedAt = time.Now()
+	rev.Result = RevisionSucceeded
+	if err != nil {
+		rev.Result = RevisionFailed
+		rev.Error = err.Error()
+	}
+
+	id, recordErr := recordRevision(app.Name, rev)
+	if recordErr != nil {
+		slog.Error("Not able to record revision", "app_name", app.Name, "error", recordErr.Error())
+	}
+
+	if err == nil && recordErr == nil {
+		app.DeployedRevision = id
+	}
+
+	return err
+}
+
+func recordRevision(appName string, rev Revision) (int, error) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	revisions, err := readHistory(appName)
+	if err != nil {
+		return 0, err
+	}
+
+	rev.ID = 1
+	if len(revisions) != 0 {
+		rev.ID = revisions[len(revisions)-1].ID + 1
+	}
+
+	revisions = append(revisions, rev)
+	if len(revisions) > maxHistory {
+		revisions = revisions[len(revisions)-maxHistory:]
+	}
+
+	data, err := json.Marshal(revisions)
+	if err != nil {
+		return 0, err
+	}
+
+	return rev.ID, os.WriteFile(historyFile(appName), data, os.ModePerm)
+}
+
+func readHistory(appName string) ([]Revision, error) {
+	data, err := os.ReadFile(historyFile(appName))
+	if errors.Is(err, os.ErrNotExist) {
+		return []Revision{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []Revision
+	if err := json.Unmarshal(data, &revisions); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func historyFile(appName string) string {
+	dir := historyDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "meltcd-history")
+		_ = os.MkdirAll(dir, os.ModePerm)
+	}
+
+	return filepath.Join(dir, filepath.Base(appName)+".json")
+}
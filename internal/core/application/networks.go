@@ -0,0 +1,140 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"log/slog"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/meltred/meltcd/spec"
+)
+
+// networks returns the docker network id of every network used by the services
+// (keyed by the name in the service file) and the drift of the existing networks from
+// the service file, missing networks are created when create is true,
+// otherwise their docker name is used as the id
+func (app *Application) networks(cli *client.Client, swarmSpec *spec.DockerSwarm, create bool) (map[string]string, []string, error) {
+	used, err := swarmSpec.UsedNetworks()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	existing, err := cli.NetworkList(context.Background(), types.NetworkListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byName := map[string]types.NetworkResource{}
+	for _, n := range existing {
+		byName[n.Name] = n
+	}
+
+	ids := map[string]string{}
+	var drift []string
+	for name, n := range used {
+		dockerName := n.DockerName(app.Name, name)
+
+		if found, ok := byName[dockerName]; ok {
+			if !n.External.External && found.Labels[stackNamespaceLabel] == app.Name {
+				drift = append(drift, networkDrift(n, found)...)
+			}
+			ids[name] = found.ID
+			continue
+		}
+
+		if n.External.External {
+			return nil, nil, fmt.Errorf("external network %s is not found, it must be created before the application", dockerName)
+		}
+
+		if !create {
+			ids[name] = dockerName
+			continue
+		}
+
+		slog.Info("Creating network", "app_name", app.Name, "network", dockerName)
+		res, err := cli.NetworkCreate(context.Background(), dockerName, n.CreateOptions(map[string]string{
+			stackNamespaceLabel: app.Name,
+		}))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create network %s: %w", dockerName, err)
+		}
+
+		if res.Warning != "" {
+			slog.Warn(res.Warning)
+		}
+
+		ids[name] = res.ID
+	}
+
+	sort.Strings(drift)
+	return ids, drift, nil
+}
+
+// networkDriftWarnings are the drift of the networks of the target state, they are reported
+// in the warnings since networks can not be updated while services are attached
+func (app *Application) networkDriftWarnings(target TargetState) []string {
+	swarmSpec, err := target.parse()
+	if err != nil {
+		return nil
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		slog.Error("Not able to create a new docker client", "error", err.Error())
+		return nil
+	}
+	defer cli.Close()
+
+	_, drift, err := app.networks(cli, &swarmSpec, false)
+	if err != nil {
+		slog.Error("Not able to check the networks", "app_name", app.Name, "error", err.Error())
+		return nil
+	}
+
+	return drift
+}
+
+// networkDrift compares the network with the service file, only the driver options set in
+// the service file are compared as docker adds its own (like the vxlan id of overlay networks)
+func networkDrift(n spec.Network, found types.NetworkResource) []string {
+	want := n.CreateOptions(nil)
+	hint := "(remove the application and create it again to recreate the network)"
+
+	var drift []string
+	if want.Driver != found.Driver {
+		drift = append(drift, fmt.Sprintf("network %s: driver is %s, the service file has %s %s", found.Name, found.Driver, want.Driver, hint))
+	}
+	if want.Internal != found.Internal {
+		drift = append(drift, fmt.Sprintf("network %s: internal is %t, the service file has %t %s", found.Name, found.Internal, want.Internal, hint))
+	}
+	if want.Attachable != found.Attachable {
+		drift = append(drift, fmt.Sprintf("network %s: attachable is %t, the service file has %t %s", found.Name, found.Attachable, want.Attachable, hint))
+	}
+
+	for k, v := range want.Options {
+		if value, ok := found.Options[k]; !ok || value != v {
+			drift = append(drift, fmt.Sprintf("network %s: driver option %s is %q, the service file has %q %s", found.Name, k, value, v, hint))
+		}
+	}
+
+	return drift
+}
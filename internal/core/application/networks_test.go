@@ -0,0 +1,49 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/meltred/meltcd/spec"
+)
+
+func TestNetworkDrift(t *testing.T) {
+	encrypted := spec.Network{DriverOpts: map[string]string{"encrypted": "true"}}
+
+	cases := []struct {
+		name  string
+		want  spec.Network
+		found types.NetworkResource
+		drift int
+	}{
+		{"default driver", spec.Network{}, types.NetworkResource{Name: "app_default", Driver: "overlay"}, 0},
+		{"options added by docker", spec.Network{}, types.NetworkResource{Name: "app_default", Driver: "overlay", Options: map[string]string{"com.docker.network.driver.overlay.vxlanid_list": "4097"}}, 0},
+		{"same options", encrypted, types.NetworkResource{Name: "app_default", Driver: "overlay", Options: map[string]string{"encrypted": "true", "com.docker.network.driver.overlay.vxlanid_list": "4097"}}, 0},
+		{"missing option", encrypted, types.NetworkResource{Name: "app_default", Driver: "overlay"}, 1},
+		{"changed driver", spec.Network{Driver: "bridge"}, types.NetworkResource{Name: "app_default", Driver: "overlay"}, 1},
+		{"changed flags", spec.Network{Internal: true, Attachable: true}, types.NetworkResource{Name: "app_default", Driver: "overlay"}, 2},
+	}
+
+	for _, c := range cases {
+		drift := networkDrift(c.want, c.found)
+		if len(drift) != c.drift {
+			t.Errorf("%s: drift = %v, want %d", c.name, drift, c.drift)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/meltred/meltcd/spec"
+)
+
+// SyncPolicy tells when the changes in git are applied to the cluster
+type SyncPolicy string
+
+const (
+	// Automated applies changes on every refresh (default)
+	Automated SyncPolicy = "automated"
+	// Manual only detects the changes (OutOfSync),
+	// they are applied with an explicit sync (meltcd app sync)
+	Manual SyncPolicy = "manual"
+)
+
+// Validate checks the settings of the application
+func (app *Application) Validate() error {
+	switch app.SyncPolicy {
+	case "", Automated, Manual:
+	default:
+		return fmt.Errorf("invalid sync_policy %q, it must be %q or %q", app.SyncPolicy, Automated, Manual)
+	}
+
+	if err := app.Source.validate(); err != nil {
+		return err
+	}
+
+	for name := range app.Parameters {
+		if !spec.ValidVariableName(name) {
+			return fmt.Errorf("invalid parameter name %q, it must start with a letter or _ and contain only letters, digits and _", name)
+		}
+	}
+
+	return nil
+}
+
+// selfHealing tells if out-of-band changes in the cluster are reverted automatically,
+// it is enabled when not specified
+func (app *Application) selfHealing() bool {
+	return app.SelfHeal == nil || *app.SelfHeal
+}
+
+// autoSync tells if changes are applied without an explicit sync,
+// it is paused by the manual sync policy and by a rollback
+func (app *Application) autoSync() bool {
+	return app.SyncPolicy != Manual && app.RolledBackTo == 0
+}
+
+// shouldApply tells if the out of sync target state should be applied now,
+// an explicit sync is always applied
+func (app *Application) shouldApply(trigger SyncType, target TargetState) bool {
+	if trigger == Synchronize {
+		return true
+	}
+
+	if !app.autoSync() {
+		return false
+	}
+
+	// git (the commit and the parameters) is same as the last sync,
+	// so the services were changed outside of meltcd
+	if target.Commit.SHA != "" && target.Commit.SHA == app.SyncedCommit.SHA && maps.Equal(target.Parameters, app.SyncedParameters) {
+		return app.selfHealing()
+	}
+
+	return true
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import "testing"
+
+func TestShouldApply(t *testing.T) {
+	disabled := false
+	synced := Commit{SHA: "a1"}
+
+	tests := []struct {
+		name    string
+		app     Application
+		trigger SyncType
+		target  TargetState
+		want    bool
+	}{
+		{"new commit", Application{SelfHeal: &disabled, SyncedCommit: synced}, ScheduledSync, TargetState{Commit: Commit{SHA: "b2"}}, true},
+		{"changed parameters", Application{SelfHeal: &disabled, SyncedCommit: synced, SyncedParameters: map[string]string{"TAG": "1"}}, ScheduledSync, TargetState{Commit: synced, Parameters: map[string]string{"TAG": "2"}}, true},
+		{"drift without self heal", Application{SelfHeal: &disabled, SyncedCommit: synced, SyncedParameters: map[string]string{"TAG": "1"}}, ScheduledSync, TargetState{Commit: synced, Parameters: map[string]string{"TAG": "1"}}, false},
+		{"drift with self heal", Application{SyncedCommit: synced}, ScheduledSync, TargetState{Commit: synced}, true},
+		{"never synced", Application{SelfHeal: &disabled}, ScheduledSync, TargetState{Commit: synced}, true},
+		{"manual policy", Application{SyncPolicy: Manual}, WebhookSync, TargetState{Commit: Commit{SHA: "b2"}}, false},
+		{"rolled back", Application{RolledBackTo: 3}, ScheduledSync, TargetState{Commit: Commit{SHA: "b2"}}, false},
+		{"rolled back explicit sync", Application{RolledBackTo: 3}, Synchronize, TargetState{Commit: Commit{SHA: "b2"}}, true},
+		{"explicit sync", Application{SyncPolicy: Manual, SelfHeal: &disabled, SyncedCommit: synced}, Synchronize, TargetState{Commit: synced}, true},
+	}
+
+	for _, test := range tests {
+		if got := test.app.shouldApply(test.trigger, test.target); got != test.want {
+			t.Errorf("%s: shouldApply = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
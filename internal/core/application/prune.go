@@ -0,0 +1,197 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"log/slog"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/meltred/meltcd/spec"
+)
+
+const stackNamespaceLabel = "com.docker.stack.namespace"
+
+// PrunePolicy removes the services, networks, configs, secrets and volumes of the application
+// (labelled com.docker.stack.namespace=<app>) which are no longer in the target state
+type PrunePolicy struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	Volumes bool `json:"volumes" yaml:"volumes"` // volumes hold data, so they are pruned only when enabled separately
+	DryRun  bool `json:"dry_run" yaml:"dry_run"` // only report what would be removed
+}
+
+type PruneResource struct {
+	Kind string `json:"kind"` // service, network, config, secret or volume
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+// PruneReport is what was removed (or would be removed in dry run) in the last sync
+type PruneReport struct {
+	DryRun    bool            `json:"dry_run"`
+	Resources []PruneResource `json:"resources"`
+	At        time.Time       `json:"at"`
+}
+
+// prune removes the resources of the application which are not in the target state
+func (app *Application) prune(cli *client.Client, swarmSpec *spec.DockerSwarm, services []swarm.ServiceSpec) error {
+	candidates, err := app.pruneCandidates(cli, swarmSpec, services)
+	if err != nil {
+		return err
+	}
+
+	if app.Prune.DryRun {
+		for _, r := range candidates {
+			slog.Info("Would prune (dry run)", "app_name", app.Name, "kind", r.Kind, "name", r.Name)
+		}
+
+		app.PruneReport = PruneReport{DryRun: true, Resources: candidates, At: time.Now()}
+		return nil
+	}
+
+	var removed []PruneResource
+	var errs []error
+
+	// services are removed first, since networks and volumes
+	// can not be removed while they are in use
+	for _, r := range candidates {
+		var err error
+		switch r.Kind {
+		case "service":
+			err = cli.ServiceRemove(context.Background(), r.ID)
+		case "network":
+			err = cli.NetworkRemove(context.Background(), r.ID)
+		case "config":
+			err = cli.ConfigRemove(context.Background(), r.ID)
+		case "secret":
+			err = cli.SecretRemove(context.Background(), r.ID)
+		case "volume":
+			err = cli.VolumeRemove(context.Background(), r.Name, false)
+		}
+
+		// configs and secrets are still used by the old tasks during a rolling update,
+		// they are removed in a later sync
+		if err != nil && (r.Kind == "config" || r.Kind == "secret") {
+			slog.Info("Not able to prune, it is still in use", "app_name", app.Name, "kind", r.Kind, "name", r.Name, "error", err.Error())
+			continue
+		}
+
+		if err != nil {
+			// it will be tried again in the next sync
+			slog.Warn("Not able to prune", "app_name", app.Name, "kind", r.Kind, "name", r.Name, "error", err.Error())
+			errs = append(errs, err)
+			continue
+		}
+
+		slog.Info("Pruned", "app_name", app.Name, "kind", r.Kind, "name", r.Name)
+		removed = append(removed, r)
+	}
+
+	app.PruneReport = PruneReport{Resources: removed, At: time.Now()}
+	return errors.Join(errs...)
+}
+
+// pruneCandidates returns the services, then networks, configs, secrets and then volumes
+// of the application which are not in the target state
+func (app *Application) pruneCandidates(cli *client.Client, swarmSpec *spec.DockerSwarm, services []swarm.ServiceSpec) ([]PruneResource, error) {
+	namespace := filters.NewArgs(filters.Arg("label", stackNamespaceLabel+"="+app.Name))
+
+	targetServices := map[string]bool{}
+	targetNetworks := map[string]bool{}
+	targetVolumes := map[string]bool{}
+
+	for name, v := range swarmSpec.Volumes {
+		targetVolumes[v.DockerName(name)] = true
+	}
+
+	for _, service := range services {
+		targetServices[service.Name] = true
+
+		for _, n := range service.TaskTemplate.Networks {
+			targetNetworks[n.Target] = true
+		}
+
+		if service.TaskTemplate.ContainerSpec != nil {
+			for _, m := range service.TaskTemplate.ContainerSpec.Mounts {
+				if m.Type == mount.TypeVolume {
+					targetVolumes[m.Source] = true
+				}
+			}
+		}
+	}
+
+	var candidates []PruneResource
+
+	runningServices, err := cli.ServiceList(context.Background(), types.ServiceListOptions{Filters: namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, svc := range runningServices {
+		if !targetServices[svc.Spec.Name] {
+			candidates = append(candidates, PruneResource{Kind: "service", Name: svc.Spec.Name, ID: svc.ID})
+		}
+	}
+
+	networks, err := cli.NetworkList(context.Background(), types.NetworkListOptions{Filters: namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, n := range networks {
+		if !targetNetworks[n.ID] && !targetNetworks[n.Name] {
+			candidates = append(candidates, PruneResource{Kind: "network", Name: n.Name, ID: n.ID})
+		}
+	}
+
+	configs, err := cli.ConfigList(context.Background(), types.ConfigListOptions{Filters: namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := cli.SecretList(context.Background(), types.SecretListOptions{Filters: namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	candidates = append(candidates, staleFileObjects(services, configs, secrets)...)
+
+	if !app.Prune.Volumes {
+		return candidates, nil
+	}
+
+	volumes, err := cli.VolumeList(context.Background(), volume.ListOptions{Filters: namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range volumes.Volumes {
+		if !targetVolumes[v.Name] {
+			candidates = append(candidates, PruneResource{Kind: "volume", Name: v.Name, ID: v.Name})
+		}
+	}
+
+	return candidates, nil
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+
+	"github.com/meltred/meltcd/internal/core/render"
+	"github.com/meltred/meltcd/spec"
+)
+
+// validate checks that the source has a service file
+func (s Source) validate() error {
+	if s.Path != "" && len(s.Paths) != 0 {
+		return errors.New("only one of path or paths can be specified")
+	}
+
+	if s.Path == "" && len(s.Paths) == 0 {
+		return errors.New("the path to service file not specified")
+	}
+
+	for _, p := range s.Paths {
+		if p == "" {
+			return errors.New("paths can not have an empty path")
+		}
+	}
+
+	if s.Template != nil && s.Path == "" {
+		return errors.New("template source needs the path to the template directory")
+	}
+
+	renderers := 0
+	for _, set := range []bool{s.Template != nil, s.Overlay != nil, s.Jsonnet != nil, s.Apps != nil} {
+		if set {
+			renderers++
+		}
+	}
+	if renderers > 1 {
+		return errors.New("only one of template, overlay, jsonnet or apps can be specified")
+	}
+
+	if s.Apps != nil && s.Path == "" {
+		return errors.New("apps source needs the path to the directory of application specs")
+	}
+
+	if s.Jsonnet != nil && s.Path == "" {
+		return errors.New("jsonnet source needs the path to the jsonnet file")
+	}
+
+	if s.Overlay != nil {
+		if s.Path == "" {
+			return errors.New("overlay source needs the path to the overlay directory")
+		}
+		for _, image := range s.Overlay.Images {
+			if _, err := render.ParseImage(image); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// serviceFiles returns the service files of the source in merge order,
+// when the path is a directory the service files in it are discovered
+func (s Source) serviceFiles(files fs.FS) ([]string, error) {
+	if len(s.Paths) != 0 {
+		result := make([]string, 0, len(s.Paths))
+		for _, p := range s.Paths {
+			result = append(result, path.Clean(p))
+		}
+		return result, nil
+	}
+
+	p := path.Clean(s.Path)
+
+	info, err := fs.Stat(files, p)
+	if err != nil || !info.IsDir() {
+		return []string{p}, nil
+	}
+
+	return render.ComposeFiles(files, p)
+}
+
+// dir is the directory of the first service file (or of the templates, or of the base
+// of the overlay), relative paths in the service files are resolved from it
+func (s Source) dir(files fs.FS) string {
+	if s.Template != nil {
+		return path.Clean(s.Path)
+	}
+
+	if s.Overlay != nil {
+		if files != nil {
+			if dir, err := render.OverlayBaseDir(files, path.Clean(s.Path)); err == nil {
+				return dir
+			}
+		}
+		return path.Clean(s.Path)
+	}
+
+	if files != nil {
+		if paths, err := s.serviceFiles(files); err == nil {
+			return path.Dir(paths[0])
+		}
+	}
+
+	if len(s.Paths) != 0 {
+		return path.Dir(path.Clean(s.Paths[0]))
+	}
+	return path.Dir(path.Clean(s.Path))
+}
+
+// serviceFile reads the service files of the source, multiple files are merged in order,
+// a template, overlay or jsonnet source is rendered
+func (s Source) serviceFile(appName string, files fs.FS) (string, error) {
+	if s.Template != nil {
+		rendered, err := render.Template(files, path.Clean(s.Path), render.TemplateOptions{
+			AppName:    appName,
+			ValueFiles: s.Template.ValueFiles,
+			Set:        s.Template.Values,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to render templates of %s: %w", s.Path, err)
+		}
+		return string(rendered), nil
+	}
+
+	if s.Overlay != nil {
+		var opts render.OverlayOptions
+		for _, image := range s.Overlay.Images {
+			img, err := render.ParseImage(image)
+			if err != nil {
+				return "", err
+			}
+			opts.Images = append(opts.Images, img)
+		}
+
+		rendered, err := render.Overlay(files, path.Clean(s.Path), opts)
+		if err != nil {
+			return "", fmt.Errorf("failed to render overlay %s: %w", s.Path, err)
+		}
+		return string(rendered), nil
+	}
+
+	if s.Jsonnet != nil {
+		rendered, err := render.Jsonnet(files, s.Path, render.JsonnetOptions{
+			ExtVars:  s.Jsonnet.ExtVars,
+			ExtCode:  s.Jsonnet.ExtCode,
+			TLAVars:  s.Jsonnet.TLAs,
+			TLACode:  s.Jsonnet.TLACode,
+			LibPaths: s.Jsonnet.LibPaths,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to evaluate jsonnet %s: %w", s.Path, err)
+		}
+		return string(rendered), nil
+	}
+
+	paths, err := s.serviceFiles(files)
+	if err != nil {
+		return "", err
+	}
+
+	contents := make([][]byte, 0, len(paths))
+	for _, p := range paths {
+		data, err := fs.ReadFile(files, p)
+		if err != nil {
+			return "", fmt.Errorf("service file %s: %w", p, err)
+		}
+		contents = append(contents, data)
+	}
+
+	if len(contents) == 1 {
+		return string(contents[0]), nil
+	}
+
+	merged, err := spec.Merge(contents...)
+	if err != nil {
+		return "", fmt.Errorf("failed to merge %v: %w", paths, err)
+	}
+
+	return string(merged), nil
+}
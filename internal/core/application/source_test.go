@@ -0,0 +1,96 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestServiceFiles(t *testing.T) {
+	files := fstest.MapFS{
+		"deploy/compose.yaml":          &fstest.MapFile{Data: []byte("services:\n  web:\n    image: nginx:1.25\n")},
+		"deploy/compose.override.yaml": &fstest.MapFile{Data: []byte("services:\n  web:\n    image: nginx:1.26\n")},
+		"legacy/docker-compose.yml":    &fstest.MapFile{Data: []byte("services: {}\n")},
+		"empty/README.md":              &fstest.MapFile{Data: []byte("nothing here")},
+	}
+
+	cases := []struct {
+		source Source
+		want   []string
+		dir    string
+	}{
+		{Source{Path: "deploy/compose.yaml"}, []string{"deploy/compose.yaml"}, "deploy"},
+		{Source{Path: "deploy"}, []string{"deploy/compose.yaml", "deploy/compose.override.yaml"}, "deploy"},
+		{Source{Path: "legacy/"}, []string{"legacy/docker-compose.yml"}, "legacy"},
+		{Source{Paths: []string{"legacy/docker-compose.yml", "./deploy/compose.yaml"}}, []string{"legacy/docker-compose.yml", "deploy/compose.yaml"}, "legacy"},
+	}
+
+	for _, c := range cases {
+		got, err := c.source.serviceFiles(files)
+		if err != nil {
+			t.Errorf("%+v: %s", c.source, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("service files of %+v = %v, want %v", c.source, got, c.want)
+		}
+		if dir := c.source.dir(files); dir != c.dir {
+			t.Errorf("dir of %+v = %q, want %q", c.source, dir, c.dir)
+		}
+	}
+
+	if _, err := (Source{Path: "empty"}).serviceFiles(files); err == nil {
+		t.Error("expected error for directory without service file")
+	}
+
+	merged, err := Source{Path: "deploy"}.serviceFile("app", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := TargetState{Spec: merged, Files: files, Dir: "deploy"}
+	swarmSpec, err := target.parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image := swarmSpec.Services["web"].Image; image != "nginx:1.26" {
+		t.Errorf("image = %q, the override file must be merged", image)
+	}
+}
+
+func TestValidateSource(t *testing.T) {
+	invalid := []Source{
+		{},
+		{Path: "compose.yaml", Paths: []string{"compose.yaml"}},
+		{Paths: []string{"compose.yaml", ""}},
+		{Paths: []string{"overlays/prod"}, Overlay: &OverlaySource{}},
+		{Path: "overlays/prod", Overlay: &OverlaySource{Images: []string{"nginx"}}},
+		{Path: "overlays/prod", Overlay: &OverlaySource{}, Template: &TemplateSource{}},
+		{Paths: []string{"main.jsonnet"}, Jsonnet: &JsonnetSource{}},
+		{Path: "main.jsonnet", Jsonnet: &JsonnetSource{}, Overlay: &OverlaySource{}},
+		{Paths: []string{"apps"}, Apps: &AppsSource{}},
+		{Path: "apps", Apps: &AppsSource{}, Template: &TemplateSource{}},
+	}
+
+	for _, s := range invalid {
+		if err := s.validate(); err == nil {
+			t.Errorf("expected error for source %+v", s)
+		}
+	}
+}
@@ -26,15 +26,66 @@ import (
 )
 
 type Spec struct {
-	Name         string `json:"name" yaml:"name"`
-	RefreshTimer string `json:"refresh_timer" yaml:"refresh_timer"` // number of minutes
-	Source       Source `json:"source" yaml:"source"`
+	Name         string      `json:"name" yaml:"name"`
+	RefreshTimer string      `json:"refresh_timer" yaml:"refresh_timer"` // number of minutes
+	Source       Source      `json:"source" yaml:"source"`
+	SyncPolicy   SyncPolicy  `json:"sync_policy" yaml:"sync_policy"`                 // automated (default) or manual
+	SelfHeal     *bool       `json:"self_heal,omitempty" yaml:"self_heal,omitempty"` // revert changes done outside of meltcd, default true
+	Prune        PrunePolicy `json:"prune" yaml:"prune"`
+
+	// Parameters are the values of the variables (like ${IMAGE_TAG}) in the service file
+	Parameters map[string]string `json:"parameters" yaml:"parameters"`
 }
 
 type Source struct {
 	RepoURL        string `json:"repoURL" yaml:"repoURL"`
 	TargetRevision string `json:"targetRevision" yaml:"targetRevision"`
-	Path           string `json:"path" yaml:"path"`
+	Path           string `json:"path" yaml:"path"` // service file, or directory with compose.yaml (and compose.override.yaml)
+
+	// Paths are service files merged in order, a later file overrides the earlier ones
+	// (like docker-compose.yml and docker-compose.prod.yml)
+	Paths []string `json:"paths,omitempty" yaml:"paths,omitempty"`
+
+	// Template renders the templates of the directory at Path, instead of reading a service file
+	Template *TemplateSource `json:"template,omitempty" yaml:"template,omitempty"`
+
+	// Overlay renders the overlay directory at Path (with overlay.yaml) on top of its base
+	Overlay *OverlaySource `json:"overlay,omitempty" yaml:"overlay,omitempty"`
+
+	// Jsonnet evaluates the jsonnet file at Path into the service file
+	Jsonnet *JsonnetSource `json:"jsonnet,omitempty" yaml:"jsonnet,omitempty"`
+
+	// Apps makes this an app of apps, Path is a directory of application specs
+	Apps *AppsSource `json:"apps,omitempty" yaml:"apps,omitempty"`
+}
+
+// TemplateSource is a directory of service file templates (like a helm chart): templates/*.yaml
+// rendered with Go templates and the values of values.yaml
+type TemplateSource struct {
+	ValueFiles []string          `json:"valueFiles,omitempty" yaml:"valueFiles,omitempty"` // relative to the directory, values.yaml by default
+	Values     map[string]string `json:"values,omitempty" yaml:"values,omitempty"`         // override the value files, like "image.tag": "1.4.2"
+}
+
+// OverlaySource is a directory with an overlay.yaml, which patches a base compose directory
+// (like kustomize): strategic merge and JSON6902 patches, images, name prefix and common labels
+type OverlaySource struct {
+	Images []string `json:"images,omitempty" yaml:"images,omitempty"` // override the images of the overlay, like "nginx=nginx:1.26"
+}
+
+// JsonnetSource is a jsonnet entrypoint which evaluates to the compose document
+// (or to a string with the compose yaml)
+type JsonnetSource struct {
+	ExtVars  map[string]string `json:"extVars,omitempty" yaml:"extVars,omitempty"`   // std.extVar strings
+	ExtCode  map[string]string `json:"extCode,omitempty" yaml:"extCode,omitempty"`   // std.extVar jsonnet code
+	TLAs     map[string]string `json:"tlas,omitempty" yaml:"tlas,omitempty"`         // top-level argument strings
+	TLACode  map[string]string `json:"tlaCode,omitempty" yaml:"tlaCode,omitempty"`   // top-level argument jsonnet code
+	LibPaths []string          `json:"libPaths,omitempty" yaml:"libPaths,omitempty"` // directories of the repository searched for imports
+}
+
+// AppsSource is a directory of application specs (yaml or json, like `meltcd app create --file`),
+// the child applications are created, updated and deleted to match it
+type AppsSource struct {
+	Recurse bool `json:"recurse,omitempty" yaml:"recurse,omitempty"` // include the specs in subdirectories
 }
 
 // parse an application from yaml source
@@ -47,6 +98,11 @@ func ParseSpecFromFile(file string) (Spec, error) {
 		return Spec{}, err
 	}
 
+	return ParseSpec(file, fileContent)
+}
+
+// ParseSpec parses the application spec, the format (yaml or json) is the extension of the file name
+func ParseSpec(file string, fileContent []byte) (Spec, error) {
 	var spec Spec
 
 	if strings.HasSuffix(file, ".yaml") || strings.HasSuffix(file, ".yml") { // nolint:all
@@ -64,22 +120,29 @@ func ParseSpecFromFile(file string) (Spec, error) {
 	return spec, nil
 }
 
-func ParseSpecFromValue(name, repo, revision, path, refresh string) (Spec, error) {
+// ParseSpecFromValue returns the application spec, multiple paths are merged in order
+func ParseSpecFromValue(name, repo, revision string, paths []string, refresh string) (Spec, error) {
 	if repo == "" {
 		return Spec{}, errors.New("the git repository not specified")
 	}
 
-	if path == "" {
+	if len(paths) == 0 || paths[0] == "" {
 		return Spec{}, errors.New("the path to Service file not specified")
 	}
 
+	source := Source{
+		RepoURL:        repo,
+		TargetRevision: revision,
+	}
+	if len(paths) == 1 {
+		source.Path = paths[0]
+	} else {
+		source.Paths = paths
+	}
+
 	return Spec{
 		Name:         name,
 		RefreshTimer: refresh,
-		Source: Source{
-			RepoURL:        repo,
-			TargetRevision: revision,
-			Path:           path,
-		},
+		Source:       source,
 	}, nil
 }
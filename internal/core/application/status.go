@@ -0,0 +1,85 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"fmt"
+	"time"
+)
+
+// SyncStatus tells whether the live state matches the target state in git,
+// it is separate from Health which tells how the running services are doing
+type SyncStatus int
+
+const (
+	SyncUnknown SyncStatus = iota
+	Synced
+	OutOfSync
+	SyncError
+)
+
+func (s SyncStatus) ToString() string {
+	switch s {
+	case SyncUnknown:
+		return "unknown"
+	case Synced:
+		return "synced"
+	case OutOfSync:
+		return "out_of_sync"
+	case SyncError:
+		return "error"
+	}
+
+	return "NA"
+}
+
+// MarshalText stores the sync status as string in applications.json and API responses
+func (s SyncStatus) MarshalText() ([]byte, error) {
+	return []byte(s.ToString()), nil
+}
+
+func (s *SyncStatus) UnmarshalText(text []byte) error {
+	for _, status := range []SyncStatus{SyncUnknown, Synced, OutOfSync, SyncError} {
+		if status.ToString() == string(text) {
+			*s = status
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid sync status: %s", text)
+}
+
+// syncStarted is called at the beginning of every sync attempt
+func (app *Application) syncStarted() {
+	app.LastSyncAttemptAt = time.Now()
+}
+
+// syncSucceeded marks the application synced to the commit and parameters
+func (app *Application) syncSucceeded(commit Commit, parameters map[string]string) {
+	app.SyncStatus = Synced
+	app.LastSyncError = ""
+	app.LastSyncedAt = time.Now()
+	app.SyncedCommit = commit
+	app.SyncedParameters = parameters
+}
+
+// syncFailed marks the application sync as failed,
+// reason tells at which step the sync failed (like "failed to get target state")
+func (app *Application) syncFailed(reason string, err error) {
+	app.SyncStatus = SyncError
+	app.LastSyncError = fmt.Sprintf("%s: %s", reason, err.Error())
+}
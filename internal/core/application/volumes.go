@@ -0,0 +1,121 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+
+	"log/slog"
+
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/meltred/meltcd/spec"
+)
+
+// volumes creates the missing volumes of the service file when create is true and returns
+// the drift of the existing volumes from the service file. External volumes are not created,
+// and volumes are local to the node, so the volumes on the manager are checked, swarm creates
+// them on the other nodes from the mount options of the services
+func (app *Application) volumes(cli *client.Client, swarmSpec *spec.DockerSwarm, create bool) ([]string, error) {
+	if len(swarmSpec.Volumes) == 0 {
+		return nil, nil
+	}
+
+	existing, err := cli.VolumeList(context.Background(), volume.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]*volume.Volume{}
+	for _, v := range existing.Volumes {
+		byName[v.Name] = v
+	}
+
+	var drift []string
+	for name, v := range swarmSpec.Volumes {
+		if v.External.External {
+			continue
+		}
+
+		options := v.CreateOptions(name, map[string]string{
+			stackNamespaceLabel: app.Name,
+		})
+
+		if found, ok := byName[options.Name]; ok {
+			drift = append(drift, volumeDrift(options, found)...)
+			continue
+		}
+
+		if !create {
+			continue
+		}
+
+		slog.Info("Creating volume", "app_name", app.Name, "volume", options.Name)
+		if _, err := cli.VolumeCreate(context.Background(), options); err != nil {
+			return nil, fmt.Errorf("failed to create volume %s: %w", options.Name, err)
+		}
+	}
+
+	sort.Strings(drift)
+	return drift, nil
+}
+
+// volumeDriftWarnings are the drift of the volumes of the target state, they are reported
+// in the warnings since a volume with data is not recreated by meltcd
+func (app *Application) volumeDriftWarnings(target TargetState) []string {
+	swarmSpec, err := target.parse()
+	if err != nil || len(swarmSpec.Volumes) == 0 {
+		return nil
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		slog.Error("Not able to create a new docker client", "error", err.Error())
+		return nil
+	}
+	defer cli.Close()
+
+	drift, err := app.volumes(cli, &swarmSpec, false)
+	if err != nil {
+		slog.Error("Not able to check the volumes", "app_name", app.Name, "error", err.Error())
+		return nil
+	}
+
+	return drift
+}
+
+// volumeDrift compares the driver and driver options of the volume with the service file
+func volumeDrift(want volume.CreateOptions, found *volume.Volume) []string {
+	var drift []string
+
+	driver := want.Driver
+	if driver == "" {
+		driver = "local"
+	}
+	if found.Driver != driver {
+		drift = append(drift, fmt.Sprintf("volume %s: driver is %s, the service file has %s (remove the volume to recreate it)", found.Name, found.Driver, driver))
+	}
+
+	if (len(want.DriverOpts) != 0 || len(found.Options) != 0) && !maps.Equal(want.DriverOpts, found.Options) {
+		drift = append(drift, fmt.Sprintf("volume %s: driver options are %v, the service file has %v (remove the volume to recreate it)", found.Name, found.Options, want.DriverOpts))
+	}
+
+	return drift
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/volume"
+	"github.com/meltred/meltcd/spec"
+)
+
+func TestVolumeDrift(t *testing.T) {
+	nfs := spec.Volume{Driver: "local", DriverOpts: map[string]string{"type": "nfs", "o": "addr=10.0.0.1"}}
+
+	cases := []struct {
+		name  string
+		want  spec.Volume
+		found volume.Volume
+		drift int
+	}{
+		{"default driver", spec.Volume{}, volume.Volume{Name: "data", Driver: "local"}, 0},
+		{"same options", nfs, volume.Volume{Name: "data", Driver: "local", Options: map[string]string{"type": "nfs", "o": "addr=10.0.0.1"}}, 0},
+		{"changed options", nfs, volume.Volume{Name: "data", Driver: "local", Options: map[string]string{"type": "nfs", "o": "addr=10.0.0.2"}}, 1},
+		{"removed options", spec.Volume{}, volume.Volume{Name: "data", Driver: "local", Options: map[string]string{"type": "tmpfs"}}, 1},
+		{"changed driver", spec.Volume{Driver: "rexray"}, volume.Volume{Name: "data", Driver: "local"}, 1},
+	}
+
+	for _, c := range cases {
+		drift := volumeDrift(c.want.CreateOptions("data", nil), &c.found)
+		if len(drift) != c.drift {
+			t.Errorf("%s: drift = %v, want %d", c.name, drift, c.drift)
+		}
+	}
+}
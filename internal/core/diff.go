@@ -0,0 +1,69 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+
+	"log/slog"
+
+	"github.com/meltred/meltcd/internal/core/application"
+)
+
+type DiffResult struct {
+	App      string                    `json:"app"`
+	Commit   application.Commit        `json:"commit"` // empty when the diff is against a local service file
+	Services []application.ServiceDiff `json:"services"`
+}
+
+// Diff returns what would change in the cluster if the application is synced,
+// serviceFile is the service file to compare with, if empty the one in git is used
+// (files it refers to, like configs, are always read from git)
+func Diff(appName string, serviceFile string) (DiffResult, error) {
+	app, exists := getApp(appName)
+	if !exists {
+		return DiffResult{}, fmt.Errorf("app does not exists, create a new application first")
+	}
+
+	if app.Source.Apps != nil {
+		return DiffResult{}, fmt.Errorf("app %s is an app of apps, it does not have services", appName)
+	}
+
+	result := DiffResult{
+		App: appName,
+	}
+
+	target, err := app.GetState()
+	if err != nil {
+		return DiffResult{}, err
+	}
+
+	if serviceFile != "" {
+		target.Spec = serviceFile
+		target.Commit = application.Commit{}
+	}
+	result.Commit = target.Commit
+
+	services, err := app.Diff(target)
+	if err != nil {
+		return DiffResult{}, err
+	}
+	slog.Info("Got diff of application", "app_name", appName, "services", len(services))
+
+	result.Services = services
+	return result, nil
+}
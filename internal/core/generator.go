@@ -0,0 +1,117 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"log/slog"
+
+	"github.com/meltred/meltcd/internal/core/application"
+)
+
+func init() {
+	application.SetChildReconciler(reconcileChildren)
+}
+
+// reconcileChildren creates, updates and deletes the child applications of the app of apps
+// parent to match the specs, applications created by users or by other apps of apps are not changed
+func reconcileChildren(parent string, specs []application.Spec, apply bool) ([]string, error) {
+	// a removed app of apps must not create its applications again
+	if _, exists := getApp(parent); !exists {
+		return nil, fmt.Errorf("app %s does not exists", parent)
+	}
+
+	var create, update []*application.Application
+	var changes []string
+	wanted := map[string]bool{}
+
+	for _, spec := range specs {
+		if spec.Name == "" {
+			return nil, errors.New("application spec without a name")
+		}
+		if spec.Name == parent {
+			return nil, fmt.Errorf("application %s can not manage itself", parent)
+		}
+		if wanted[spec.Name] {
+			return nil, fmt.Errorf("application %s is specified more than once", spec.Name)
+		}
+		wanted[spec.Name] = true
+
+		child := application.New(spec)
+		child.Parent = parent
+		if err := child.Validate(); err != nil {
+			return nil, fmt.Errorf("application %s: %w", spec.Name, err)
+		}
+
+		existing, exists := getApp(spec.Name)
+		switch {
+		case !exists:
+			create = append(create, &child)
+			changes = append(changes, "create "+spec.Name)
+		case existing.Parent != parent:
+			return nil, fmt.Errorf("application %s already exists and is not managed by %s", spec.Name, parent)
+		case !existing.SameSpec(spec):
+			update = append(update, &child)
+			changes = append(changes, "update "+spec.Name)
+		}
+	}
+
+	var remove []string
+	for _, app := range children(parent) {
+		if !wanted[app.Name] {
+			remove = append(remove, app.Name)
+			changes = append(changes, "delete "+app.Name)
+		}
+	}
+
+	if !apply {
+		return changes, nil
+	}
+
+	var errs []error
+	for _, app := range create {
+		if err := Register(app); err != nil {
+			errs = append(errs, fmt.Errorf("create %s: %w", app.Name, err))
+		}
+	}
+	for _, app := range update {
+		if err := Update(app); err != nil {
+			errs = append(errs, fmt.Errorf("update %s: %w", app.Name, err))
+		}
+	}
+	for _, name := range remove {
+		if err := RemoveApplication(name); err != nil {
+			errs = append(errs, fmt.Errorf("delete %s: %w", name, err))
+		}
+	}
+
+	slog.Info("Reconciled applications", "parent", parent, "changes", changes)
+	return changes, errors.Join(errs...)
+}
+
+// children are the applications managed by the app of apps parent
+func children(parent string) []*application.Application {
+	var result []*application.Application
+	for _, app := range apps() {
+		if app.Parent == parent {
+			result = append(result, app)
+		}
+	}
+	return result
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/meltred/meltcd/internal/core/application"
+)
+
+func childSpec(name string, path string) application.Spec {
+	return application.Spec{
+		Name:         name,
+		RefreshTimer: "3m",
+		Source:       application.Source{RepoURL: "https://github.com/meltred/apps", Path: path},
+	}
+}
+
+func TestReconcileChildren(t *testing.T) {
+	defer func(apps []*application.Application) { Applications = apps }(Applications)
+
+	unchanged := application.New(childSpec("api", "api/compose.yaml"))
+	unchanged.Parent = "apps"
+	changed := application.New(childSpec("web", "web/compose.yaml"))
+	changed.Parent = "apps"
+	removed := application.New(childSpec("old", "old/compose.yaml"))
+	removed.Parent = "apps"
+	other := application.New(childSpec("db", "db/compose.yaml"))
+
+	Applications = []*application.Application{
+		{Name: "apps", Source: application.Source{Path: "apps", Apps: &application.AppsSource{}}},
+		&unchanged, &changed, &removed, &other,
+	}
+
+	changes, err := reconcileChildren("apps", []application.Spec{
+		childSpec("api", "api/compose.yaml"),
+		childSpec("web", "web/compose.prod.yaml"),
+		childSpec("worker", "worker/compose.yaml"),
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slices.Sort(changes)
+	want := []string{"create worker", "delete old", "update web"}
+	if !slices.Equal(changes, want) {
+		t.Errorf("changes = %v, want %v", changes, want)
+	}
+
+	if changed.Source.Path != "web/compose.yaml" || len(Applications) != 5 {
+		t.Error("applications must not be changed when apply is false")
+	}
+}
+
+func TestReconcileChildrenErrors(t *testing.T) {
+	defer func(apps []*application.Application) { Applications = apps }(Applications)
+
+	other := application.New(childSpec("db", "db/compose.yaml"))
+	Applications = []*application.Application{
+		{Name: "apps", Source: application.Source{Path: "apps", Apps: &application.AppsSource{}}},
+		&other,
+	}
+
+	tests := []struct {
+		name   string
+		parent string
+		specs  []application.Spec
+		err    string
+	}{
+		{"not managed", "apps", []application.Spec{childSpec("db", "db/compose.yaml")}, "is not managed by apps"},
+		{"duplicate", "apps", []application.Spec{childSpec("web", "a"), childSpec("web", "b")}, "more than once"},
+		{"itself", "apps", []application.Spec{childSpec("apps", "a")}, "can not manage itself"},
+		{"no name", "apps", []application.Spec{childSpec("", "a")}, "without a name"},
+		{"invalid", "apps", []application.Spec{childSpec("web", "")}, "application web"},
+		{"removed parent", "removed", nil, "does not exists"},
+	}
+
+	for _, test := range tests {
+		_, err := reconcileChildren(test.parent, test.specs, false)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error %q, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestReconcileChildrenStopsRemoved(t *testing.T) {
+	defer func(apps []*application.Application) { Applications = apps }(Applications)
+
+	removed := application.New(childSpec("old", "old/compose.yaml"))
+	removed.Parent = "apps"
+	removed.Source.RepoURL = "file://" + t.TempDir() + "/missing"
+	removed.RefreshTimer = "1h"
+	removed.Start()
+	defer removed.Stop()
+
+	Applications = []*application.Application{
+		{Name: "apps", Source: application.Source{Path: "apps", Apps: &application.AppsSource{}}},
+		&removed,
+	}
+
+	// removing the services fails without docker, the application is stopped before that
+	_, _ = reconcileChildren("apps", nil, true)
+
+	if removed.TrySync(application.ScheduledSync, "") {
+		t.Error("removed application still syncs")
+	}
+}
+
+func TestReconcileChildrenKeepsRollback(t *testing.T) {
+	defer func(apps []*application.Application) { Applications = apps }(Applications)
+
+	child := application.New(childSpec("web", "web/compose.yaml"))
+	child.Parent = "apps"
+	child.Source.RepoURL = "file://" + t.TempDir() + "/missing"
+	child.RefreshTimer = "1h"
+	child.Start()
+	child.Rollback(application.Revision{ID: 3}, "admin")
+	child.Stop()
+
+	Applications = []*application.Application{
+		{Name: "apps", Source: application.Source{Path: "apps", Apps: &application.AppsSource{}}},
+		&child,
+	}
+
+	spec := childSpec("web", "web/compose.prod.yaml")
+	spec.Source.RepoURL = child.Source.RepoURL
+	changes, err := reconcileChildren("apps", []application.Spec{spec}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 1 || child.Source.Path != "web/compose.prod.yaml" {
+		t.Fatalf("child is not updated, changes = %v", changes)
+	}
+	if child.RolledBackTo != 3 {
+		t.Errorf("rollback of the child is undone by the app of apps, rolled back to = %d", child.RolledBackTo)
+	}
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitcache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// FS returns the files of the commit as a read only file system,
+// paths are relative to the root of the repository
+func (r *Repo) FS(commit plumbing.Hash) (fs.FS, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.repo == nil {
+		return nil, errors.New("repository is not fetched yet")
+	}
+
+	c, err := r.repo.CommitObject(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	return &commitFS{repo: r, tree: tree, modTime: c.Committer.When}, nil
+}
+
+// commitFS is the tree of a commit, the repository is locked while
+// objects are read since a fetch can be writing to it
+type commitFS struct {
+	repo    *Repo
+	tree    *object.Tree
+	modTime time.Time
+}
+
+var _ fs.ReadDirFS = (*commitFS)(nil)
+var _ fs.ReadFileFS = (*commitFS)(nil)
+
+func (c *commitFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	c.repo.mu.Lock()
+	defer c.repo.mu.Unlock()
+
+	if name == "." {
+		return c.dir(name, c.tree)
+	}
+
+	entry, err := c.tree.FindEntry(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if entry.Mode == filemode.Dir {
+		tree, err := c.tree.Tree(name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return c.dir(name, tree)
+	}
+
+	if entry.Mode == filemode.Submodule {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("submodules are not supported")}
+	}
+
+	file, err := c.tree.TreeEntryFile(entry)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	reader, err := file.Reader()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	return &commitFile{
+		Reader: bytes.NewReader(data),
+		info:   fileInfo{name: path.Base(name), size: int64(len(data)), mode: fileMode(entry.Mode), modTime: c.modTime},
+	}, nil
+}
+
+func (c *commitFS) ReadFile(name string) ([]byte, error) {
+	f, err := c.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	file, ok := f.(*commitFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+
+	return io.ReadAll(file)
+}
+
+func (c *commitFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := c.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dir, ok := f.(*commitDir)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	return dir.ReadDir(-1)
+}
+
+func (c *commitFS) dir(name string, tree *object.Tree) (fs.File, error) {
+	entries := make([]fs.DirEntry, 0, len(tree.Entries))
+	for _, e := range tree.Entries {
+		var size int64
+		if e.Mode.IsFile() {
+			size, _ = tree.Size(e.Name)
+		}
+
+		entries = append(entries, fs.FileInfoToDirEntry(fileInfo{
+			name:    e.Name,
+			size:    size,
+			mode:    fileMode(e.Mode),
+			modTime: c.modTime,
+		}))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return &commitDir{
+		info:    fileInfo{name: path.Base(name), mode: fs.ModeDir | 0o555, modTime: c.modTime},
+		entries: entries,
+	}, nil
+}
+
+func fileMode(m filemode.FileMode) fs.FileMode {
+	switch m {
+	case filemode.Dir:
+		return fs.ModeDir | 0o555
+	case filemode.Executable:
+		return 0o555
+	case filemode.Symlink:
+		return fs.ModeSymlink | 0o444
+	}
+	return 0o444
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i fileInfo) Name() string       { return i.name }
+func (i fileInfo) Size() int64        { return i.size }
+func (i fileInfo) Mode() fs.FileMode  { return i.mode }
+func (i fileInfo) ModTime() time.Time { return i.modTime }
+func (i fileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i fileInfo) Sys() any           { return nil }
+
+type commitFile struct {
+	*bytes.Reader
+	info fileInfo
+}
+
+func (f *commitFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *commitFile) Close() error               { return nil }
+
+type commitDir struct {
+	info    fileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *commitDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *commitDir) Close() error               { return nil }
+
+func (d *commitDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+func (d *commitDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
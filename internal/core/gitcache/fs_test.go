@@ -0,0 +1,96 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitcache
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestFS(t *testing.T) {
+	src := t.TempDir()
+
+	files := map[string]string{
+		"service.yml":          "services: {}\n",
+		"config/nginx.conf":    "server {}\n",
+		"config/env/.env.prod": "A=1\n",
+	}
+
+	worktree, err := git.PlainInit(src, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := worktree.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		p := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hash, err := w.Commit("init", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Setup(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := Get(src)
+	if err := repo.Fetch("HEAD", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := repo.FS(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fstest.TestFS(fsys, "service.yml", "config/nginx.conf", "config/env/.env.prod"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("content of %s = %q, want %q", name, data, content)
+		}
+	}
+}
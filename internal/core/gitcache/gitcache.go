@@ -0,0 +1,221 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gitcache keeps one persistent (bare) clone per git repository on disk,
+// applications pointing at the same repository share the clone and every
+// sync only fetches what has changed since the last fetch.
+package gitcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"log/slog"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// fetches of a repository within this interval are served from the cache,
+// so dozens of applications polling the same repository only fetch it once
+const minFetchInterval = 10 * time.Second
+
+const remoteName = "origin"
+
+// remoteHead is where the default branch of the remote (HEAD) is stored
+const remoteHead = plumbing.ReferenceName("refs/remotes/origin/HEAD")
+
+var refSpecs = []config.RefSpec{
+	"+refs/heads/*:refs/heads/*",
+	"+refs/tags/*:refs/tags/*",
+	"+HEAD:" + config.RefSpec(remoteHead),
+}
+
+var (
+	cacheDir string
+	mu       sync.Mutex
+	repos    = map[string]*Repo{}
+)
+
+// Repo is a cached clone of a git repository
+type Repo struct {
+	URL         string
+	path        string
+	mu          sync.Mutex
+	repo        *git.Repository
+	lastFetched time.Time
+}
+
+// Setup sets the directory where the repositories are cloned
+func Setup(dir string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	cacheDir = dir
+	mu.Unlock()
+
+	return nil
+}
+
+// Get returns the cached repository for url, the repository is
+// cloned (or opened from disk) on the first Fetch
+func Get(url string) *Repo {
+	mu.Lock()
+	defer mu.Unlock()
+
+	key := normalizeURL(url)
+	if r, ok := repos[key]; ok {
+		return r
+	}
+
+	dir := cacheDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "meltcd-git")
+	}
+
+	r := &Repo{
+		URL:  url,
+		path: filepath.Join(dir, dirName(key)),
+	}
+	repos[key] = r
+
+	return r
+}
+
+// Fetch brings the clone up to date with the remote,
+// revision is fetched too if it is a ref outside of branches and tags (like "refs/pull/1/head")
+func (r *Repo) Fetch(revision, username, password string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	specs := refSpecs
+	customRef := isCustomRef(revision)
+	if customRef {
+		specs = append(specs[:len(specs):len(specs)], config.RefSpec("+"+revision+":"+revision))
+	}
+
+	if r.repo != nil && time.Since(r.lastFetched) < minFetchInterval &&
+		(!customRef || r.hasReference(plumbing.ReferenceName(revision))) {
+		slog.Info("Using recently fetched repository", "repo", r.URL)
+		return nil
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	slog.Info("Fetching repository", "repo", r.URL)
+	err := r.repo.Fetch(&git.FetchOptions{
+		RemoteName: remoteName,
+		RefSpecs:   specs,
+		Auth:       getAuth(username, password),
+		Force:      true,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return err
+	}
+
+	r.lastFetched = time.Now()
+	return nil
+}
+
+// Invalidate makes the next Fetch go to the remote even if the
+// repository was fetched recently, it is used when a push is notified
+func (r *Repo) Invalidate() {
+	r.mu.Lock()
+	r.lastFetched = time.Time{}
+	r.mu.Unlock()
+}
+
+func (r *Repo) hasReference(name plumbing.ReferenceName) bool {
+	if r.repo == nil {
+		return false
+	}
+
+	_, err := r.repo.Reference(name, true)
+	return err == nil
+}
+
+func (r *Repo) open() error {
+	if r.repo != nil {
+		return nil
+	}
+
+	repo, err := git.PlainOpen(r.path)
+	if err == nil {
+		r.repo = repo
+		return nil
+	}
+
+	if !errors.Is(err, git.ErrRepositoryNotExists) {
+		return err
+	}
+
+	slog.Info("Cloning repository into cache", "repo", r.URL, "path", r.path)
+	repo, err = git.PlainInit(r.path, true)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.CreateRemote(&config.RemoteConfig{
+		Name:  remoteName,
+		URLs:  []string{r.URL},
+		Fetch: refSpecs,
+	})
+	if err != nil {
+		return err
+	}
+
+	r.repo = repo
+	return nil
+}
+
+func getAuth(username, password string) transport.AuthMethod {
+	if username == "" && password == "" {
+		return nil
+	}
+
+	return &http.BasicAuth{
+		Username: username,
+		Password: password,
+	}
+}
+
+// normalizeURL makes "https://host/repo", "https://host/repo/" and
+// "https://host/repo.git" share a single clone
+func normalizeURL(url string) string {
+	url = strings.TrimSuffix(strings.TrimSpace(url), "/")
+	return strings.TrimSuffix(url, ".git")
+}
+
+// dirName is the name of the directory for the repository,
+// like "infra-test-1a2b3c4d5e6f7a8b"
+func dirName(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return path.Base(url) + "-" + hex.EncodeToString(sum[:8])
+}
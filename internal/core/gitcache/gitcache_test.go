@@ -0,0 +1,218 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitcache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// sourceRepo is a local repository served with a file:// url
+type sourceRepo struct {
+	t    *testing.T
+	dir  string
+	repo *git.Repository
+}
+
+func newSourceRepo(t *testing.T) *sourceRepo {
+	dir := t.TempDir()
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &sourceRepo{t: t, dir: dir, repo: repo}
+}
+
+func (s *sourceRepo) url() string {
+	return "file://" + s.dir
+}
+
+// commit writes service.yml with content and commits it
+func (s *sourceRepo) commit(content string) plumbing.Hash {
+	w, err := s.repo.Worktree()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(s.dir, "service.yml"), []byte(content), 0o644); err != nil {
+		s.t.Fatal(err)
+	}
+	if _, err := w.Add("service.yml"); err != nil {
+		s.t.Fatal(err)
+	}
+
+	hash, err := w.Commit(content, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+
+	return hash
+}
+
+func (s *sourceRepo) tag(name string, hash plumbing.Hash, annotated bool) {
+	var opts *git.CreateTagOptions
+	if annotated {
+		opts = &git.CreateTagOptions{
+			Message: name,
+			Tagger:  &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		}
+	}
+
+	if _, err := s.repo.CreateTag(name, hash, opts); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+func (s *sourceRepo) branch(name string, hash plumbing.Hash) {
+	if err := s.repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(name), hash)); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	if err := Setup(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	src := newSourceRepo(t)
+	first := src.commit("first")
+	src.tag("v1.0.0", first, false)
+	src.branch("feature", first)
+	second := src.commit("second")
+	src.tag("v1.2.0", second, true)
+	src.tag("v2.0.0-rc.1", second, false)
+	head := src.commit("third")
+
+	repo := Get(src.url())
+	if _, err := repo.Resolve("HEAD"); err == nil {
+		t.Error("expected error for a repository which is not fetched yet")
+	}
+
+	if err := repo.Fetch("HEAD", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		revision string
+		want     plumbing.Hash
+	}{
+		{"", head},
+		{"HEAD", head},
+		{"master", head},
+		{"feature", first},
+		{"refs/heads/feature", first},
+		{"v1.0.0", first},
+		{"v1.2.0", second}, // annotated tag is peeled to its commit
+		{first.String(), first},
+		{second.String()[:7], second},
+		{"v1.*", second},
+		{">=1.0 <1.2", first},
+	}
+
+	for _, c := range cases {
+		got, err := repo.Resolve(c.revision)
+		if err != nil {
+			t.Errorf("Resolve(%q) failed: %s", c.revision, err.Error())
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Resolve(%q) = %s, want %s", c.revision, got, c.want)
+		}
+	}
+
+	for _, revision := range []string{"missing", ">=3", "refs/pull/1/head"} {
+		if _, err := repo.Resolve(revision); err == nil {
+			t.Errorf("expected error for revision %q", revision)
+		}
+	}
+}
+
+func TestSharedClone(t *testing.T) {
+	if err := Setup(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	src := newSourceRepo(t)
+	repo := Get(src.url())
+
+	for _, url := range []string{src.url() + "/", src.url() + ".git", " " + src.url()} {
+		if Get(url) != repo {
+			t.Errorf("%q does not share the clone of %q", url, src.url())
+		}
+	}
+}
+
+func TestMinFetchInterval(t *testing.T) {
+	if err := Setup(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	src := newSourceRepo(t)
+	first := src.commit("first")
+
+	repo := Get(src.url())
+	if err := repo.Fetch("HEAD", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	resolve := func() plumbing.Hash {
+		hash, err := repo.Resolve("HEAD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	second := src.commit("second")
+
+	// fetched within the interval, the cached clone is used
+	if err := repo.Fetch("HEAD", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := resolve(); got != first {
+		t.Errorf("HEAD = %s, want the cached %s", got, first)
+	}
+
+	// the interval is over
+	repo.lastFetched = time.Now().Add(-minFetchInterval)
+	if err := repo.Fetch("HEAD", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := resolve(); got != second {
+		t.Errorf("HEAD = %s, want %s after the fetch interval", got, second)
+	}
+
+	// a push is notified
+	third := src.commit("third")
+	repo.Invalidate()
+	if err := repo.Fetch("HEAD", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := resolve(); got != third {
+		t.Errorf("HEAD = %s, want %s after invalidate", got, third)
+	}
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitcache
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Resolve finds the commit a revision points to, the revision can be
+//
+//	"HEAD" (or empty)        the default branch of the remote
+//	"main"                   a branch
+//	"v1.2.0"                 a tag
+//	"3f2a9c1" or full sha    a commit
+//	"refs/pull/1/head"       any other ref (must be fetched with Fetch first)
+//	"v1.2.*", ">=1.4 <2"     the highest semver tag matching the constraint
+func (r *Repo) Resolve(revision string) (plumbing.Hash, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.repo == nil {
+		return plumbing.ZeroHash, errors.New("repository is not fetched yet")
+	}
+
+	revision = strings.TrimSpace(revision)
+
+	var candidates []plumbing.ReferenceName
+	switch {
+	case revision == "" || revision == "HEAD":
+		candidates = []plumbing.ReferenceName{remoteHead}
+	case strings.HasPrefix(revision, "refs/"):
+		candidates = []plumbing.ReferenceName{plumbing.ReferenceName(revision)}
+	default:
+		candidates = []plumbing.ReferenceName{
+			plumbing.NewBranchReferenceName(revision),
+			plumbing.NewTagReferenceName(revision),
+		}
+	}
+
+	for _, name := range candidates {
+		ref, err := r.repo.Reference(name, true)
+		if err != nil {
+			continue
+		}
+
+		return r.peel(ref.Hash())
+	}
+
+	if isHash(revision) {
+		hash, err := r.repo.ResolveRevision(plumbing.Revision(revision))
+		if err == nil {
+			return *hash, nil
+		}
+	}
+
+	constraint, err := semver.NewConstraint(revision)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("revision %q not found", revision)
+	}
+
+	tags, err := r.tags()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	tag, found := latestMatchingTag(tags, constraint)
+	if !found {
+		return plumbing.ZeroHash, fmt.Errorf("no tag matches revision %q", revision)
+	}
+
+	ref, err := r.repo.Reference(plumbing.NewTagReferenceName(tag), true)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return r.peel(ref.Hash())
+}
+
+// peel returns the commit of an annotated tag,
+// hash is returned as it is if its already a commit
+func (r *Repo) peel(hash plumbing.Hash) (plumbing.Hash, error) {
+	tag, err := r.repo.TagObject(hash)
+	if err != nil {
+		return hash, nil
+	}
+
+	commit, err := tag.Commit()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return commit.Hash, nil
+}
+
+func (r *Repo) tags() ([]string, error) {
+	iter, err := r.repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		tags = append(tags, ref.Name().Short())
+		return nil
+	})
+
+	return tags, err
+}
+
+// Commit returns the commit object of hash
+func (r *Repo) Commit(hash plumbing.Hash) (*object.Commit, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.repo == nil {
+		return nil, errors.New("repository is not fetched yet")
+	}
+
+	return r.repo.CommitObject(hash)
+}
+
+// latestMatchingTag returns the highest semver tag satisfying the constraint,
+// tags which are not semver are ignored
+func latestMatchingTag(tags []string, constraint *semver.Constraints) (string, bool) {
+	var latest *semver.Version
+	var latestTag string
+
+	for _, tag := range tags {
+		version, err := semver.NewVersion(tag)
+		if err != nil {
+			continue
+		}
+
+		if !constraint.Check(version) {
+			continue
+		}
+
+		if latest == nil || version.GreaterThan(latest) {
+			latest = version
+			latestTag = tag
+		}
+	}
+
+	return latestTag, latest != nil
+}
+
+// isCustomRef tells if the revision is a ref which is not fetched by default
+func isCustomRef(revision string) bool {
+	return strings.HasPrefix(revision, "refs/") &&
+		!strings.HasPrefix(revision, "refs/heads/") &&
+		!strings.HasPrefix(revision, "refs/tags/")
+}
+
+// isHash tells if the revision looks like a full or short commit sha
+func isHash(revision string) bool {
+	if len(revision) < 4 || len(revision) > 40 {
+		return false
+	}
+
+	for _, c := range revision {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitcache
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+func TestLatestMatchingTag(t *testing.T) {
+	tags := []string{"v1.0.0", "v1.2.0", "v1.2.7", "v1.4.1", "v1.9.0", "v2.0.0", "latest", "v2.1.0-rc.1"}
+
+	testCases := map[string]string{
+		"v1.2.*":   "v1.2.7",
+		">=1.4 <2": "v1.9.0",
+		"~1.4":     "v1.4.1",
+		"v1.0.0":   "v1.0.0",
+		">=2":      "v2.0.0",
+		">=3":      "",
+	}
+
+	for c, expected := range testCases {
+		constraint, err := semver.NewConstraint(c)
+		if err != nil {
+			t.Error(err.Error())
+			continue
+		}
+
+		tag, found := latestMatchingTag(tags, constraint)
+		if tag != expected || found != (expected != "") {
+			t.Errorf("constraint %q resolved to %q, expected %q", c, tag, expected)
+		}
+	}
+}
+
+func TestIsHash(t *testing.T) {
+	testCases := map[string]bool{
+		"3f2a9c1": true,
+		"3F2A9C1": true,
+		"3f2a9c1e5b7d9f1a3c5e7b9d1f3a5c7e9b1d3f5a": true,
+		"abc":    false,
+		"main":   false,
+		"v1.2.0": false,
+		"3f2a9c1e5b7d9f1a3c5e7b9d1f3a5c7e9b1d3f5a0": false,
+	}
+
+	for revision, expected := range testCases {
+		if isHash(revision) != expected {
+			t.Errorf("isHash(%q) should be %v", revision, expected)
+		}
+	}
+}
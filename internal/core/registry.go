@@ -20,10 +20,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 	"github.com/meltred/meltcd/internal/core/application"
 
@@ -32,6 +34,10 @@ import (
 
 var Applications []*application.Application
 
+// appsMu guards Applications, apps are registered and removed by the api
+// and by the sync goroutines of the app of apps
+var appsMu sync.RWMutex
+
 func Register(app *application.Application) error {
 	slog.Info("Registering application", "name", app.Name)
 
@@ -40,18 +46,26 @@ func Register(app *application.Application) error {
 		return fmt.Errorf("app already exists with name: %s", app.Name)
 	}
 
-	app.SyncTrigger = make(chan application.SyncType, 1)
+	if err := app.Validate(); err != nil {
+		return err
+	}
 
 	timeOfCreation := time.Now()
 	app.CreatedAt = timeOfCreation
 	app.UpdatedAt = timeOfCreation
-	app.LastSyncedAt = timeOfCreation
 
 	// clearing the current state, so it can be fetch again
-	app.LiveState = ""
+	app.SyncStatus = application.SyncUnknown
 
-	go app.Run()
+	appsMu.Lock()
+	if _, exists := findApp(app.Name); exists {
+		appsMu.Unlock()
+		return fmt.Errorf("app already exists with name: %s", app.Name)
+	}
+	// started with the lock held, so a removal right after registering stops it
+	app.Start()
 	Applications = append(Applications, app)
+	appsMu.Unlock()
 
 	slog.Info("Registered!")
 	return nil
@@ -67,13 +81,21 @@ func Update(app *application.Application) error {
 		return fmt.Errorf("app does not exists, create a new application first")
 	}
 
+	if err := app.Validate(); err != nil {
+		return err
+	}
+
 	runningApp.RefreshTimer = app.RefreshTimer
 	runningApp.Source = app.Source
+	runningApp.SyncPolicy = app.SyncPolicy
+	runningApp.SelfHeal = app.SelfHeal
+	runningApp.Prune = app.Prune
+	runningApp.Parameters = app.Parameters
 
 	runningApp.UpdatedAt = time.Now()
 
 	// Sync the application as new update is done
-	runningApp.SyncTrigger <- application.UpdateSync
+	runningApp.RequestSync(application.UpdateSync, "")
 
 	return nil
 }
@@ -94,25 +116,33 @@ type AppList struct {
 }
 
 type AppStatus struct {
-	ID           uint32    `json:"id"`
-	Name         string    `json:"name"`
-	Health       string    `json:"health"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAT    time.Time `json:"updated_at"`
-	LastSyncedAt time.Time `json:"last_synced_at"`
+	ID                uint32    `json:"id"`
+	Name              string    `json:"name"`
+	Health            string    `json:"health"`
+	SyncStatus        string    `json:"sync_status"`
+	LastSyncError     string    `json:"last_sync_error"`
+	Warnings          []string  `json:"warnings"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAT         time.Time `json:"updated_at"`
+	LastSyncAttemptAt time.Time `json:"last_sync_attempt_at"`
+	LastSyncedAt      time.Time `json:"last_synced_at"`
 }
 
 func List() AppList {
 	var res AppList
 
-	for index, app := range Applications {
+	for index, app := range apps() {
 		res.Data = append(res.Data, AppStatus{
-			ID:           uint32(index),
-			Name:         app.Name,
-			Health:       app.Health.ToString(),
-			CreatedAt:    app.CreatedAt,
-			UpdatedAT:    app.UpdatedAt,
-			LastSyncedAt: app.LastSyncedAt,
+			ID:                uint32(index),
+			Name:              app.Name,
+			Health:            app.Health.ToString(),
+			SyncStatus:        app.SyncStatus.ToString(),
+			LastSyncError:     app.LastSyncError,
+			Warnings:          app.Warnings,
+			CreatedAt:         app.CreatedAt,
+			UpdatedAT:         app.UpdatedAt,
+			LastSyncAttemptAt: app.LastSyncAttemptAt,
+			LastSyncedAt:      app.LastSyncedAt,
 		})
 	}
 
@@ -120,6 +150,14 @@ func List() AppList {
 }
 
 func getApp(name string) (*application.Application, bool) {
+	appsMu.RLock()
+	defer appsMu.RUnlock()
+
+	return findApp(name)
+}
+
+// findApp is getApp for callers holding appsMu
+func findApp(name string) (*application.Application, bool) {
 	for _, app := range Applications {
 		if app.Name == name {
 			return app, true
@@ -129,19 +167,66 @@ func getApp(name string) (*application.Application, bool) {
 	return &application.Application{}, false
 }
 
-func Refresh(appName string) error {
+// Refresh syncs the application now, username is recorded in the revision history
+func Refresh(appName string, username string) error {
 	app, exists := getApp(appName)
 	if !exists {
 		return fmt.Errorf("app does not exists, create a new application first")
 	}
 
-	app.SyncTrigger <- application.Synchronize
+	app.RequestSync(application.Synchronize, username)
 
 	return nil
 }
 
+// History returns the deployed revisions of the application, latest first
+func History(appName string) ([]application.Revision, error) {
+	if _, exists := getApp(appName); !exists {
+		return nil, fmt.Errorf("app does not exists, create a new application first")
+	}
+
+	revisions, err := application.History(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Reverse(revisions)
+	return revisions, nil
+}
+
+// Rollback re-applies a previous revision of the application (the previous successful
+// one when revision is 0) and pauses auto sync until the next explicit sync
+func Rollback(appName string, revision int, username string) (application.Revision, error) {
+	app, exists := getApp(appName)
+	if !exists {
+		return application.Revision{}, fmt.Errorf("app does not exists, create a new application first")
+	}
+
+	rev, err := application.FindRevision(appName, revision, app.DeployedRevision)
+	if err != nil {
+		return application.Revision{}, err
+	}
+
+	if rev.Result != application.RevisionSucceeded {
+		return application.Revision{}, fmt.Errorf("revision %d was not deployed successfully", rev.ID)
+	}
+
+	slog.Info("Rollback requested", "app_name", appName, "revision", rev.ID, "by", username)
+	app.Rollback(rev, username)
+
+	return rev, nil
+}
+
+// apps is a copy of Applications, so it can be ranged over while apps are registered or removed
+func apps() []*application.Application {
+	appsMu.RLock()
+	defer appsMu.RUnlock()
+
+	return slices.Clone(Applications)
+}
+
 func getRegistryData() ([]byte, error) {
-	result, err := json.Marshal(Applications)
+	result, err := json.Marshal(apps())
 	if err != nil {
 		return []byte{}, err
 	}
@@ -157,18 +242,30 @@ func loadRegistryData(d *[]byte) error {
 	}
 
 	for _, app := range load {
-		app.SyncTrigger = make(chan application.SyncType, 1)
-		go app.Run()
+		app.Start()
 	}
 
+	appsMu.Lock()
 	Applications = load
+	appsMu.Unlock()
 
 	return nil
 }
 
 func RemoveApplication(appName string) error {
 	slog.Info("Removing application", "app name", appName)
-	go makeAppStatusProcessing(appName)
+	// the application is stopped first, so it does not deploy the removed services again
+	if app, exists := getApp(appName); exists {
+		app.Stop()
+		app.Health = application.Progressing
+	}
+
+	// the applications of an app of apps are removed with it
+	for _, child := range children(appName) {
+		if err := RemoveApplication(child.Name); err != nil {
+			return err
+		}
+	}
 
 	cli, err := client.NewClientWithOpts(client.FromEnv)
 	if err != nil {
@@ -180,9 +277,6 @@ func RemoveApplication(appName string) error {
 		return err
 	}
 
-	// set (unique) of network to remove
-	networksToRemove := map[string]bool{}
-
 	for _, svc := range runningService {
 		name := svc.Spec.Labels["com.docker.stack.namespace"]
 
@@ -190,14 +284,27 @@ func RemoveApplication(appName string) error {
 			if err := cli.ServiceRemove(context.Background(), svc.ID); err != nil {
 				return err
 			}
-
-			for _, nets := range svc.Spec.TaskTemplate.Networks {
-				// nets.Target is the network ID
-				networksToRemove[nets.Target] = true
-			}
 		}
 	}
 
+	namespace := filters.NewArgs(filters.Arg("label", "com.docker.stack.namespace="+appName))
+
+	removeConfigsAndSecrets(cli, namespace)
+
+	// only the networks created for the application are removed,
+	// external networks may be used by other applications
+	networks, err := cli.NetworkList(context.Background(), types.NetworkListOptions{
+		Filters: namespace,
+	})
+	if err != nil {
+		return err
+	}
+
+	networksToRemove := map[string]bool{}
+	for _, n := range networks {
+		networksToRemove[n.ID] = true
+	}
+
 	var wg sync.WaitGroup
 
 	for networkID := range networksToRemove {
@@ -227,7 +334,34 @@ func RemoveApplication(appName string) error {
 	return nil
 }
 
+// removeConfigsAndSecrets removes the configs and secrets created for the application,
+// external ones are not labelled so they are kept
+func removeConfigsAndSecrets(cli *client.Client, namespace filters.Args) {
+	configs, err := cli.ConfigList(context.Background(), types.ConfigListOptions{Filters: namespace})
+	if err != nil {
+		slog.Error(err.Error())
+	}
+	for _, c := range configs {
+		if err := cli.ConfigRemove(context.Background(), c.ID); err != nil {
+			slog.Error(err.Error())
+		}
+	}
+
+	secrets, err := cli.SecretList(context.Background(), types.SecretListOptions{Filters: namespace})
+	if err != nil {
+		slog.Error(err.Error())
+	}
+	for _, s := range secrets {
+		if err := cli.SecretRemove(context.Background(), s.ID); err != nil {
+			slog.Error(err.Error())
+		}
+	}
+}
+
 func removeSvcFromApps(appName string) {
+	appsMu.Lock()
+	defer appsMu.Unlock()
+
 	tmp := make([]*application.Application, 0)
 
 	for _, app := range Applications {
@@ -239,14 +373,6 @@ func removeSvcFromApps(appName string) {
 	Applications = tmp
 }
 
-func makeAppStatusProcessing(appName string) {
-	for _, app := range Applications {
-		if app.Name == appName {
-			app.Health = application.Progressing
-		}
-	}
-}
-
 func Recreate(appName string) error {
 	data, err := Details(appName)
 	if err != nil {
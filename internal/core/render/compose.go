@@ -0,0 +1,56 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// ComposeFileNames are the service files discovered in a directory (like docker compose does),
+// the first one found is used with its override file (like compose.override.yaml)
+var ComposeFileNames = []string{
+	"compose.yaml",
+	"compose.yml",
+	"docker-compose.yaml",
+	"docker-compose.yml",
+}
+
+// ComposeFiles returns the service files of the directory, the first compose file found and its override file
+func ComposeFiles(files fs.FS, dir string) ([]string, error) {
+	for _, name := range ComposeFileNames {
+		base := path.Join(dir, name)
+		if _, err := fs.Stat(files, base); err != nil {
+			continue
+		}
+
+		result := []string{base}
+		ext := path.Ext(name)
+		for _, override := range []string{".override.yaml", ".override.yml"} {
+			overrideFile := path.Join(dir, name[:len(name)-len(ext)]+override)
+			if _, err := fs.Stat(files, overrideFile); err == nil {
+				result = append(result, overrideFile)
+				break
+			}
+		}
+
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("no service file found in directory %s, expected one of %v", dir, ComposeFileNames)
+}
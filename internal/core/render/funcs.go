@@ -0,0 +1,311 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// funcMap are the functions of the templates, a subset of the sprig functions used by helm charts
+func funcMap(root *template.Template) template.FuncMap {
+	return template.FuncMap{
+		// values
+		"default":  defaultValue,
+		"empty":    empty,
+		"coalesce": coalesce,
+		"ternary":  ternary,
+		"required": required,
+
+		// strings
+		"quote":      quote,
+		"squote":     squote,
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"title":      title,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix string, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix string, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old string, new string, s string) string { return strings.ReplaceAll(s, old, new) },
+		"contains":   func(substr string, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix string, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix string, s string) bool { return strings.HasSuffix(s, suffix) },
+		"repeat":     func(count int, s string) string { return strings.Repeat(s, count) },
+		"splitList":  func(sep string, s string) []string { return strings.Split(s, sep) },
+		"join":       join,
+		"indent":     indent,
+		"nindent":    func(spaces int, s string) string { return "\n" + indent(spaces, s) },
+		"toString":   toString,
+
+		// lists and dicts
+		"list":   func(items ...interface{}) []interface{} { return items },
+		"dict":   dict,
+		"hasKey": hasKey,
+		"keys":   keys,
+
+		// numbers
+		"int": toInt,
+		"add": func(a, b interface{}) (int64, error) {
+			return arithmetic(a, b, func(x, y int64) int64 { return x + y })
+		},
+		"sub": func(a, b interface{}) (int64, error) {
+			return arithmetic(a, b, func(x, y int64) int64 { return x - y })
+		},
+		"mul": func(a, b interface{}) (int64, error) {
+			return arithmetic(a, b, func(x, y int64) int64 { return x * y })
+		},
+		"div":   divide,
+		"until": until,
+
+		// encoding
+		"toYaml":    toYAML,
+		"toJson":    toJSON,
+		"b64enc":    func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+		"b64dec":    b64dec,
+		"sha256sum": sha256sum,
+
+		// templates
+		"include": func(name string, data interface{}) (string, error) {
+			var buf bytes.Buffer
+			err := root.ExecuteTemplate(&buf, name, data)
+			return buf.String(), err
+		},
+		"tpl": func(text string, data interface{}) (string, error) {
+			t, err := root.Clone()
+			if err != nil {
+				return "", err
+			}
+			t, err = t.New("tpl").Parse(text)
+			if err != nil {
+				return "", err
+			}
+			var buf bytes.Buffer
+			err = t.Execute(&buf, data)
+			return buf.String(), err
+		},
+	}
+}
+
+// empty tells if the value is the zero value, an empty string, list or map
+func empty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return value.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return value.IsNil()
+	}
+	return value.IsZero()
+}
+
+func defaultValue(def interface{}, v ...interface{}) interface{} {
+	if len(v) == 0 || empty(v[0]) {
+		return def
+	}
+	return v[0]
+}
+
+func coalesce(values ...interface{}) interface{} {
+	for _, v := range values {
+		if !empty(v) {
+			return v
+		}
+	}
+	return nil
+}
+
+func ternary(whenTrue interface{}, whenFalse interface{}, condition bool) interface{} {
+	if condition {
+		return whenTrue
+	}
+	return whenFalse
+}
+
+func required(message string, v interface{}) (interface{}, error) {
+	if empty(v) {
+		return nil, errors.New(message)
+	}
+	return v, nil
+}
+
+func toString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func quote(values ...interface{}) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, strconv.Quote(toString(v)))
+	}
+	return strings.Join(quoted, " ")
+}
+
+func squote(values ...interface{}) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, "'"+strings.ReplaceAll(toString(v), "'", "''")+"'")
+	}
+	return strings.Join(quoted, " ")
+}
+
+func title(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+func join(sep string, list interface{}) string {
+	value := reflect.ValueOf(list)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return toString(list)
+	}
+
+	items := make([]string, 0, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		items = append(items, toString(value.Index(i).Interface()))
+	}
+	return strings.Join(items, sep)
+}
+
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+func dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("dict expects pairs of key and value")
+	}
+
+	result := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		result[toString(pairs[i])] = pairs[i+1]
+	}
+	return result, nil
+}
+
+func hasKey(m map[string]interface{}, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+func keys(m map[string]interface{}) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func toInt64(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case uint64:
+		return int64(n), nil
+	case float64:
+		return int64(n), nil
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+	case nil:
+		return 0, nil
+	}
+	return 0, fmt.Errorf("%v is not a number", v)
+}
+
+func toInt(v interface{}) (int, error) {
+	n, err := toInt64(v)
+	return int(n), err
+}
+
+func arithmetic(a interface{}, b interface{}, op func(int64, int64) int64) (int64, error) {
+	x, err := toInt64(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := toInt64(b)
+	if err != nil {
+		return 0, err
+	}
+	return op(x, y), nil
+}
+
+func divide(a interface{}, b interface{}) (int64, error) {
+	y, err := toInt64(b)
+	if err != nil {
+		return 0, err
+	}
+	if y == 0 {
+		return 0, errors.New("division by zero")
+	}
+	return arithmetic(a, b, func(x, y int64) int64 { return x / y })
+}
+
+func until(count int) []int {
+	result := make([]int, 0, count)
+	for i := 0; i < count; i++ {
+		result = append(result, i)
+	}
+	return result
+}
+
+func toYAML(v interface{}) (string, error) {
+	out, err := yaml3.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func toJSON(v interface{}) (string, error) {
+	out, err := json.Marshal(v)
+	return string(out), err
+}
+
+func sha256sum(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func b64dec(s string) (string, error) {
+	out, err := base64.StdEncoding.DecodeString(s)
+	return string(out), err
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+
+	"github.com/google/go-jsonnet"
+)
+
+// JsonnetOptions are the external variables and top-level arguments of the entrypoint,
+// the *Code variants are jsonnet code and the others are strings
+type JsonnetOptions struct {
+	ExtVars  map[string]string
+	ExtCode  map[string]string
+	TLAVars  map[string]string
+	TLACode  map[string]string
+	LibPaths []string // directories of the repository searched for imports, after the importing file directory
+}
+
+// Jsonnet evaluates the jsonnet entrypoint file of the repository into a service file,
+// the result is the compose document (as JSON), or a string with the compose yaml
+// (like std.manifestYamlDoc)
+func Jsonnet(files fs.FS, file string, opts JsonnetOptions) ([]byte, error) {
+	vm := jsonnet.MakeVM()
+	vm.Importer(&fsImporter{files: files, libPaths: opts.LibPaths, cache: map[string]jsonnet.Contents{}})
+
+	for k, v := range opts.ExtVars {
+		vm.ExtVar(k, v)
+	}
+	for k, v := range opts.ExtCode {
+		vm.ExtCode(k, v)
+	}
+	for k, v := range opts.TLAVars {
+		vm.TLAVar(k, v)
+	}
+	for k, v := range opts.TLACode {
+		vm.TLACode(k, v)
+	}
+
+	out, err := vm.EvaluateFile(path.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest string
+	if err := json.Unmarshal([]byte(out), &manifest); err == nil {
+		return []byte(manifest), nil
+	}
+
+	var document map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &document); err != nil {
+		return nil, errors.New("jsonnet must evaluate to an object or a string with the compose yaml")
+	}
+
+	return []byte(out), nil
+}
+
+// fsImporter imports the files of the repository, relative to the importing file
+// and then to the library paths
+type fsImporter struct {
+	files    fs.FS
+	libPaths []string
+	cache    map[string]jsonnet.Contents
+}
+
+func (i *fsImporter) Import(importedFrom, importedPath string) (jsonnet.Contents, string, error) {
+	dirs := append([]string{path.Dir(importedFrom)}, i.libPaths...)
+
+	for _, dir := range dirs {
+		p, err := repoPath(dir, importedPath)
+		if err != nil {
+			return jsonnet.Contents{}, "", fmt.Errorf("can not import %s, %w", importedPath, err)
+		}
+
+		if contents, ok := i.cache[p]; ok {
+			return contents, p, nil
+		}
+
+		data, err := fs.ReadFile(i.files, p)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return jsonnet.Contents{}, "", err
+		}
+
+		contents := jsonnet.MakeContentsRaw(data)
+		i.cache[p] = contents
+		return contents, p, nil
+	}
+
+	return jsonnet.Contents{}, "", fmt.Errorf("can not import %s, file is not found", importedPath)
+}
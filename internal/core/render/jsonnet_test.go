@@ -0,0 +1,114 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/meltred/meltcd/spec"
+)
+
+func jsonnetFiles() fstest.MapFS {
+	return fstest.MapFS{
+		"deploy/main.jsonnet": &fstest.MapFile{Data: []byte(`
+local web = import 'web.libsonnet';
+local utils = import 'utils.libsonnet';
+
+function(replicas=1, tag='latest') {
+  services: {
+    web: web.service(tag, replicas) + { environment: { ENV: std.extVar('env') } },
+  },
+  networks: utils.networks(['front']),
+}
+`)},
+		"deploy/web.libsonnet": &fstest.MapFile{Data: []byte(`{
+  service(tag, replicas):: {
+    image: 'nginx:' + tag,
+    deploy: { replicas: replicas },
+    networks: ['front'],
+  },
+}
+`)},
+		"vendor/utils.libsonnet": &fstest.MapFile{Data: []byte(`{
+  networks(names):: { [n]: { driver: 'overlay' } for n in names },
+}
+`)},
+		"deploy/yaml.jsonnet": &fstest.MapFile{Data: []byte(`std.manifestYamlDoc({ services: { web: { image: 'nginx' } } })`)},
+	}
+}
+
+func TestJsonnet(t *testing.T) {
+	rendered, err := Jsonnet(jsonnetFiles(), "deploy/main.jsonnet", JsonnetOptions{
+		ExtVars:  map[string]string{"env": "prod"},
+		TLAVars:  map[string]string{"tag": "1.26"},
+		TLACode:  map[string]string{"replicas": "3"},
+		LibPaths: []string{"vendor"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := spec.Load(rendered, nil, "deploy", nil)
+	if err != nil {
+		t.Fatalf("rendered service file is not valid: %s\n%s", err.Error(), rendered)
+	}
+
+	web := d.Services["web"]
+	if web.Image != "nginx:1.26" || web.Environment["ENV"] != "prod" {
+		t.Errorf("web = %+v", web)
+	}
+	if web.Deploy.Replicas == nil || *web.Deploy.Replicas != 3 {
+		t.Errorf("replicas = %v, want 3", web.Deploy.Replicas)
+	}
+	if d.Networks["front"].Driver != "overlay" {
+		t.Errorf("networks = %+v", d.Networks)
+	}
+
+	rendered, err = Jsonnet(jsonnetFiles(), "deploy/yaml.jsonnet", JsonnetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := spec.Load(rendered, nil, "deploy", nil); err != nil || d.Services["web"].Image != "nginx" {
+		t.Errorf("yaml string output = %s (%v)", rendered, err)
+	}
+}
+
+func TestJsonnetErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		err  string
+	}{
+		{"missing ext var", "{ env: std.extVar('env') }", "Undefined external variable: env"},
+		{"missing import", "import 'missing.libsonnet'", "file is not found"},
+		{"import outside of repository", "import '../../secret.jsonnet'", "outside of the repository"},
+		{"syntax error", "{ services: ", "bad.jsonnet"},
+		{"not an object", "[1, 2]", "must evaluate to an object"},
+	}
+
+	for _, test := range tests {
+		files := jsonnetFiles()
+		files["deploy/bad.jsonnet"] = &fstest.MapFile{Data: []byte(test.code)}
+
+		_, err := Jsonnet(files, "deploy/bad.jsonnet", JsonnetOptions{})
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error %q, got %v", test.name, test.err, err)
+		}
+	}
+}
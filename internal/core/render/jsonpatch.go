@@ -0,0 +1,281 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// patchOperation is a JSON6902 operation, the value is any yaml value
+type patchOperation struct {
+	Op    string     `yaml:"op"`
+	Path  string     `yaml:"path"`
+	From  string     `yaml:"from"`
+	Value yaml3.Node `yaml:"value"`
+}
+
+// readJSONPatch reads the operations of the patch file or the inline patch,
+// the name is used in errors
+func readJSONPatch(files fs.FS, dir string, p JSONPatch) ([]patchOperation, string, error) {
+	if (p.Path == "") == (p.Patch == "") {
+		return nil, "", errors.New("json patch must have either a path or an inline patch")
+	}
+
+	name := "inline"
+	data := []byte(p.Patch)
+	if p.Path != "" {
+		name = p.Path
+
+		var err error
+		if data, err = readFile(files, dir, p.Path); err != nil {
+			return nil, "", fmt.Errorf("json patch %s: %w", name, err)
+		}
+	}
+
+	// JSON is valid yaml, so the patch can be written in either
+	var operations []patchOperation
+	if err := yaml3.Unmarshal(data, &operations); err != nil {
+		return nil, "", fmt.Errorf("json patch %s: %w", name, err)
+	}
+
+	return operations, name, nil
+}
+
+func (o patchOperation) apply(root *yaml3.Node) error {
+	switch o.Op {
+	case "add", "replace", "test":
+		if o.Value.Kind == 0 {
+			return fmt.Errorf("%s %s: value is required", o.Op, o.Path)
+		}
+	case "move", "copy":
+		if o.From == "" {
+			return fmt.Errorf("%s %s: from is required", o.Op, o.Path)
+		}
+	}
+
+	switch o.Op {
+	case "add":
+		return addNode(root, o.Path, copyYAML(&o.Value))
+	case "remove":
+		_, err := removeNode(root, o.Path)
+		return err
+	case "replace":
+		if _, err := removeNode(root, o.Path); err != nil {
+			return err
+		}
+		return addNode(root, o.Path, copyYAML(&o.Value))
+	case "move":
+		if o.Path == o.From || strings.HasPrefix(o.Path, o.From+"/") {
+			return fmt.Errorf("move %s: can not move into itself", o.From)
+		}
+		node, err := removeNode(root, o.From)
+		if err != nil {
+			return err
+		}
+		return addNode(root, o.Path, node)
+	case "copy":
+		node, err := findNode(root, o.From)
+		if err != nil {
+			return err
+		}
+		return addNode(root, o.Path, copyYAML(node))
+	case "test":
+		node, err := findNode(root, o.Path)
+		if err != nil {
+			return err
+		}
+		if !equalYAML(node, &o.Value) {
+			return fmt.Errorf("test %s: value is not equal", o.Path)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported operation %q", o.Op)
+	}
+}
+
+// splitPointer splits the JSON pointer into the path of the parent and the last token
+func splitPointer(pointer string) ([]string, string, error) {
+	if pointer == "" {
+		return nil, "", errors.New("the whole document can not be patched")
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, "", fmt.Errorf("invalid path %q, it must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+
+	return tokens[:len(tokens)-1], tokens[len(tokens)-1], nil
+}
+
+func walk(root *yaml3.Node, tokens []string, pointer string) (*yaml3.Node, error) {
+	node := root
+	for _, token := range tokens {
+		next, err := child(node, token)
+		if err != nil {
+			return nil, fmt.Errorf("path %s: %w", pointer, err)
+		}
+		node = next
+	}
+	return node, nil
+}
+
+func child(node *yaml3.Node, token string) (*yaml3.Node, error) {
+	switch node.Kind {
+	case yaml3.MappingNode:
+		if value := mappingGet(node, token); value != nil {
+			return value, nil
+		}
+		return nil, fmt.Errorf("key %s is not found", token)
+	case yaml3.SequenceNode:
+		i, err := sequenceIndex(node, token, false)
+		if err != nil {
+			return nil, err
+		}
+		return node.Content[i], nil
+	default:
+		return nil, fmt.Errorf("%s is not a mapping or a list", token)
+	}
+}
+
+// sequenceIndex parses the index of the token, "-" (or the length when adding) is the end of the list
+func sequenceIndex(node *yaml3.Node, token string, adding bool) (int, error) {
+	if adding && token == "-" {
+		return len(node.Content), nil
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid list index %q", token)
+	}
+
+	max := len(node.Content) - 1
+	if adding {
+		max = len(node.Content)
+	}
+	if i > max {
+		return 0, fmt.Errorf("list index %d is out of range", i)
+	}
+
+	return i, nil
+}
+
+func findNode(root *yaml3.Node, pointer string) (*yaml3.Node, error) {
+	parents, last, err := splitPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return walk(root, append(parents, last), pointer)
+}
+
+func addNode(root *yaml3.Node, pointer string, value *yaml3.Node) error {
+	parents, last, err := splitPointer(pointer)
+	if err != nil {
+		return err
+	}
+
+	parent, err := walk(root, parents, pointer)
+	if err != nil {
+		return err
+	}
+
+	switch parent.Kind {
+	case yaml3.MappingNode:
+		mappingSet(parent, last, value)
+	case yaml3.SequenceNode:
+		i, err := sequenceIndex(parent, last, true)
+		if err != nil {
+			return fmt.Errorf("path %s: %w", pointer, err)
+		}
+		parent.Content = append(parent.Content[:i], append([]*yaml3.Node{value}, parent.Content[i:]...)...)
+	default:
+		return fmt.Errorf("path %s: parent is not a mapping or a list", pointer)
+	}
+
+	return nil
+}
+
+// removeNode removes the value at the pointer and returns it
+func removeNode(root *yaml3.Node, pointer string) (*yaml3.Node, error) {
+	parents, last, err := splitPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	parent, err := walk(root, parents, pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parent.Kind {
+	case yaml3.MappingNode:
+		for i := 0; i+1 < len(parent.Content); i += 2 {
+			if parent.Content[i].Value == last {
+				node := parent.Content[i+1]
+				parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+				return node, nil
+			}
+		}
+		return nil, fmt.Errorf("path %s: key %s is not found", pointer, last)
+	case yaml3.SequenceNode:
+		i, err := sequenceIndex(parent, last, false)
+		if err != nil {
+			return nil, fmt.Errorf("path %s: %w", pointer, err)
+		}
+		node := parent.Content[i]
+		parent.Content = append(parent.Content[:i], parent.Content[i+1:]...)
+		return node, nil
+	default:
+		return nil, fmt.Errorf("path %s: parent is not a mapping or a list", pointer)
+	}
+}
+
+func copyYAML(node *yaml3.Node) *yaml3.Node {
+	c := *node
+	c.Content = make([]*yaml3.Node, len(node.Content))
+	for i, n := range node.Content {
+		c.Content[i] = copyYAML(n)
+	}
+	return &c
+}
+
+// equalYAML compares the values of the nodes, the order of mapping keys does not matter
+func equalYAML(a *yaml3.Node, b *yaml3.Node) bool {
+	var x, y interface{}
+	if a.Decode(&x) != nil || b.Decode(&y) != nil {
+		return false
+	}
+
+	ax, err := yaml3.Marshal(x)
+	if err != nil {
+		return false
+	}
+	by, err := yaml3.Marshal(y)
+	if err != nil {
+		return false
+	}
+
+	return string(ax) == string(by)
+}
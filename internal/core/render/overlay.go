@@ -0,0 +1,566 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/meltred/meltcd/spec"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// OverlayFile is the file of an overlay directory which describes the changes to the base
+const OverlayFile = "overlay.yaml"
+
+// maxOverlayDepth limits the overlays of overlays, so a cycle of bases is an error
+const maxOverlayDepth = 10
+
+// OverlaySpec is the overlay.yaml of an overlay directory (like a kustomization), the changes are
+// applied to the base in order: strategic merge patches, JSON6902 patches, images,
+// name prefix and common labels
+type OverlaySpec struct {
+	Base                  string            `yaml:"base"`                  // compose directory, compose file or another overlay directory
+	PatchesStrategicMerge []string          `yaml:"patchesStrategicMerge"` // compose files merged with the compose override rules
+	PatchesJSON6902       []JSONPatch       `yaml:"patchesJson6902"`
+	Images                []Image           `yaml:"images"`
+	NamePrefix            string            `yaml:"namePrefix"`   // prefix of the services, networks, volumes, configs and secrets
+	CommonLabels          map[string]string `yaml:"commonLabels"` // labels of the services, networks and volumes
+}
+
+// JSONPatch is a file (path) or an inline list (patch) of JSON6902 operations
+// (add, remove, replace, move, copy and test) against the compose yaml
+type JSONPatch struct {
+	Path  string `yaml:"path"`
+	Patch string `yaml:"patch"`
+}
+
+// Image changes the image of the services using the image name
+type Image struct {
+	Name    string `yaml:"name"`
+	NewName string `yaml:"newName"`
+	NewTag  string `yaml:"newTag"`
+	Digest  string `yaml:"digest"`
+}
+
+// OverlayOptions are the changes of the application on top of the overlay
+type OverlayOptions struct {
+	Images []Image // applied after the images of the overlay
+}
+
+// ParseImage parses an image override like "nginx=registry.io/nginx:1.26" or "nginx:1.26"
+// (only the tag) or "nginx@sha256:..." (only the digest)
+func ParseImage(s string) (Image, error) {
+	name, newImage, hasNewImage := strings.Cut(s, "=")
+	if !hasNewImage {
+		newImage = name
+	}
+
+	ref := parseImageRef(newImage)
+	img := Image{Name: parseImageRef(name).name, NewTag: ref.tag, Digest: ref.digest}
+	if img.Name == "" || ref.name == "" || (!hasNewImage && ref.tag == "" && ref.digest == "") {
+		return Image{}, fmt.Errorf("invalid image %q, expected NAME=IMAGE[:TAG] or NAME:TAG", s)
+	}
+
+	if hasNewImage {
+		img.NewName = ref.name
+	}
+	return img, nil
+}
+
+// Overlay renders the service file of the overlay directory dir
+func Overlay(files fs.FS, dir string, opts OverlayOptions) ([]byte, error) {
+	root, err := renderOverlay(files, dir, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, img := range opts.Images {
+		setImage(root, img)
+	}
+
+	return yaml3.Marshal(root)
+}
+
+// OverlayBaseDir returns the directory of the base service file of the overlay directory,
+// relative paths in the service file are resolved from it
+func OverlayBaseDir(files fs.FS, dir string) (string, error) {
+	for depth := 0; depth < maxOverlayDepth; depth++ {
+		overlay, err := readOverlay(files, dir)
+		if err != nil {
+			return "", err
+		}
+
+		base, err := repoPath(dir, overlay.Base)
+		if err != nil {
+			return "", fmt.Errorf("base %s: %w", overlay.Base, err)
+		}
+
+		if !isOverlay(files, base) {
+			if info, err := fs.Stat(files, base); err == nil && !info.IsDir() {
+				return path.Dir(base), nil
+			}
+			return base, nil
+		}
+		dir = base
+	}
+
+	return "", errors.New("too many nested overlays, the bases may have a cycle")
+}
+
+func renderOverlay(files fs.FS, dir string, depth int) (*yaml3.Node, error) {
+	if depth >= maxOverlayDepth {
+		return nil, errors.New("too many nested overlays, the bases may have a cycle")
+	}
+
+	overlay, err := readOverlay(files, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := repoPath(dir, overlay.Base)
+	if err != nil {
+		return nil, fmt.Errorf("base %s: %w", overlay.Base, err)
+	}
+
+	var baseFile []byte
+	if isOverlay(files, base) {
+		root, err := renderOverlay(files, base, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if baseFile, err = yaml3.Marshal(root); err != nil {
+			return nil, err
+		}
+	} else if baseFile, err = readBase(files, base); err != nil {
+		return nil, err
+	}
+
+	// strategic merge patches are compose files merged with the compose override rules
+	contents := [][]byte{baseFile}
+	for _, p := range overlay.PatchesStrategicMerge {
+		data, err := readFile(files, dir, p)
+		if err != nil {
+			return nil, fmt.Errorf("patch %s: %w", p, err)
+		}
+		contents = append(contents, data)
+	}
+
+	merged, err := spec.Merge(contents...)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml3.Node
+	if err := yaml3.Unmarshal(merged, &doc); err != nil {
+		return nil, err
+	}
+
+	root := &yaml3.Node{Kind: yaml3.MappingNode, Tag: "!!map"}
+	if doc.Kind != 0 && len(doc.Content) != 0 {
+		root = doc.Content[0]
+	}
+
+	for _, p := range overlay.PatchesJSON6902 {
+		operations, name, err := readJSONPatch(files, dir, p)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, op := range operations {
+			if err := op.apply(root); err != nil {
+				return nil, fmt.Errorf("json patch %s, operation %d: %w", name, i+1, err)
+			}
+		}
+	}
+
+	for _, img := range overlay.Images {
+		setImage(root, img)
+	}
+
+	if overlay.NamePrefix != "" {
+		addNamePrefix(root, overlay.NamePrefix)
+	}
+
+	if len(overlay.CommonLabels) != 0 {
+		addCommonLabels(root, overlay.CommonLabels)
+	}
+
+	return root, nil
+}
+
+func readOverlay(files fs.FS, dir string) (OverlaySpec, error) {
+	data, err := fs.ReadFile(files, path.Join(dir, OverlayFile))
+	if err != nil {
+		return OverlaySpec{}, fmt.Errorf("overlay %s: %w", dir, err)
+	}
+
+	var overlay OverlaySpec
+	if err := yaml3.Unmarshal(data, &overlay); err != nil {
+		return OverlaySpec{}, fmt.Errorf("overlay %s: %w", path.Join(dir, OverlayFile), err)
+	}
+
+	if overlay.Base == "" {
+		return OverlaySpec{}, fmt.Errorf("overlay %s: base is required", path.Join(dir, OverlayFile))
+	}
+
+	return overlay, nil
+}
+
+func isOverlay(files fs.FS, dir string) bool {
+	_, err := fs.Stat(files, path.Join(dir, OverlayFile))
+	return err == nil
+}
+
+// readBase reads the compose file, or the compose files discovered in the directory
+func readBase(files fs.FS, base string) ([]byte, error) {
+	info, err := fs.Stat(files, base)
+	if err != nil {
+		return nil, fmt.Errorf("base %s: %w", base, err)
+	}
+
+	paths := []string{base}
+	if info.IsDir() {
+		if paths, err = ComposeFiles(files, base); err != nil {
+			return nil, err
+		}
+	}
+
+	contents := make([][]byte, 0, len(paths))
+	for _, p := range paths {
+		data, err := fs.ReadFile(files, p)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, data)
+	}
+
+	if len(contents) == 1 {
+		return contents[0], nil
+	}
+	return spec.Merge(contents...)
+}
+
+type imageRef struct {
+	name   string
+	tag    string
+	digest string
+}
+
+// parseImageRef splits the image into the name, tag and digest, the port of a registry
+// (like localhost:5000/app) is part of the name
+func parseImageRef(image string) imageRef {
+	var ref imageRef
+
+	image, ref.digest, _ = strings.Cut(image, "@")
+
+	lastSlash := strings.LastIndex(image, "/")
+	if colon := strings.LastIndex(image, ":"); colon > lastSlash {
+		ref.tag = image[colon+1:]
+		image = image[:colon]
+	}
+	ref.name = image
+
+	return ref
+}
+
+func (r imageRef) String() string {
+	image := r.name
+	if r.tag != "" {
+		image += ":" + r.tag
+	}
+	if r.digest != "" {
+		image += "@" + r.digest
+	}
+	return image
+}
+
+// setImage changes the image of the services with the image name
+func setImage(root *yaml3.Node, img Image) {
+	for _, service := range mappingValues(mappingGet(root, "services")) {
+		image := mappingGet(service, "image")
+		if image == nil || image.Kind != yaml3.ScalarNode {
+			continue
+		}
+
+		ref := parseImageRef(image.Value)
+		if ref.name != img.Name {
+			continue
+		}
+
+		if img.NewName != "" {
+			ref.name = img.NewName
+		}
+		if img.NewTag != "" {
+			ref.tag = img.NewTag
+			ref.digest = ""
+		}
+		if img.Digest != "" {
+			ref.digest = img.Digest
+		}
+
+		image.Value = ref.String()
+		image.Tag = "!!str"
+	}
+}
+
+// addNamePrefix prefixes the services, networks, volumes, configs and secrets, and their references
+// in the services. External resources keep their docker name, the default network is not prefixed
+func addNamePrefix(root *yaml3.Node, prefix string) {
+	renamed := map[string]map[string]string{}
+
+	for _, kind := range []string{"networks", "volumes", "configs", "secrets"} {
+		renamed[kind] = map[string]string{}
+
+		resources := mappingGet(root, kind)
+		if resources == nil || resources.Kind != yaml3.MappingNode {
+			continue
+		}
+
+		for i := 0; i+1 < len(resources.Content); i += 2 {
+			key, value := resources.Content[i], resources.Content[i+1]
+			if kind == "networks" && key.Value == spec.DefaultNetwork {
+				continue
+			}
+
+			if isExternal(value) && mappingGet(value, "name") == nil {
+				if value.Kind != yaml3.MappingNode {
+					*value = yaml3.Node{Kind: yaml3.MappingNode, Tag: "!!map"}
+				}
+				mappingSet(value, "name", scalar(key.Value))
+			}
+
+			renamed[kind][key.Value] = prefix + key.Value
+			key.Value = prefix + key.Value
+		}
+	}
+
+	services := mappingGet(root, "services")
+	if services == nil || services.Kind != yaml3.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(services.Content); i += 2 {
+		services.Content[i].Value = prefix + services.Content[i].Value
+
+		service := services.Content[i+1]
+		renameServiceNetworks(mappingGet(service, "networks"), renamed["networks"])
+		renameServiceVolumes(mappingGet(service, "volumes"), renamed["volumes"])
+		renameFileObjects(mappingGet(service, "configs"), renamed["configs"], configTarget)
+		renameFileObjects(mappingGet(service, "secrets"), renamed["secrets"], secretTarget)
+	}
+}
+
+func isExternal(node *yaml3.Node) bool {
+	external := mappingGet(node, "external")
+	if external == nil {
+		return false
+	}
+	return external.Kind == yaml3.MappingNode || external.Value == "true"
+}
+
+func renameServiceNetworks(node *yaml3.Node, names map[string]string) {
+	if node == nil {
+		return
+	}
+
+	switch node.Kind {
+	case yaml3.SequenceNode:
+		for _, item := range node.Content {
+			if name, ok := names[item.Value]; ok {
+				item.Value = name
+			}
+		}
+	case yaml3.MappingNode:
+		for i := 0; i < len(node.Content); i += 2 {
+			if name, ok := names[node.Content[i].Value]; ok {
+				node.Content[i].Value = name
+			}
+		}
+	}
+}
+
+func renameServiceVolumes(node *yaml3.Node, names map[string]string) {
+	if node == nil || node.Kind != yaml3.SequenceNode {
+		return
+	}
+
+	for _, item := range node.Content {
+		if item.Kind == yaml3.MappingNode {
+			if source := mappingGet(item, "source"); source != nil {
+				if name, ok := names[source.Value]; ok {
+					source.Value = name
+				}
+			}
+			continue
+		}
+
+		source, rest, ok := strings.Cut(item.Value, ":")
+		if name, found := names[source]; ok && found {
+			item.Value = name + ":" + rest
+		}
+	}
+}
+
+// renameFileObjects renames the sources of the configs or secrets, the target of the short syntax
+// depends on the source so it is kept using the long syntax
+func renameFileObjects(node *yaml3.Node, names map[string]string, defaultTarget func(string) string) {
+	if node == nil || node.Kind != yaml3.SequenceNode {
+		return
+	}
+
+	for _, item := range node.Content {
+		if item.Kind != yaml3.MappingNode {
+			name, ok := names[item.Value]
+			if !ok {
+				continue
+			}
+			original := item.Value
+			*item = yaml3.Node{Kind: yaml3.MappingNode, Tag: "!!map"}
+			mappingSet(item, "source", scalar(name))
+			mappingSet(item, "target", scalar(defaultTarget(original)))
+			continue
+		}
+
+		source := mappingGet(item, "source")
+		if source == nil {
+			continue
+		}
+		name, ok := names[source.Value]
+		if !ok {
+			continue
+		}
+		if target := mappingGet(item, "target"); target == nil || target.Value == "" {
+			mappingSet(item, "target", scalar(defaultTarget(source.Value)))
+		}
+		source.Value = name
+	}
+}
+
+// addCommonLabels adds the labels to the services (deploy.labels) and to the networks and volumes
+// created for the application, the labels of the service file are kept
+func addCommonLabels(root *yaml3.Node, labels map[string]string) {
+	for _, service := range mappingValues(mappingGet(root, "services")) {
+		if service.Kind != yaml3.MappingNode {
+			continue
+		}
+
+		deploy := mappingGet(service, "deploy")
+		if deploy == nil || deploy.Kind != yaml3.MappingNode {
+			deploy = &yaml3.Node{Kind: yaml3.MappingNode, Tag: "!!map"}
+			mappingSet(service, "deploy", deploy)
+		}
+		mappingSet(deploy, "labels", withLabels(mappingGet(deploy, "labels"), labels))
+	}
+
+	for _, kind := range []string{"networks", "volumes"} {
+		for _, resource := range mappingValues(mappingGet(root, kind)) {
+			if isExternal(resource) {
+				continue
+			}
+			if resource.Kind != yaml3.MappingNode {
+				*resource = yaml3.Node{Kind: yaml3.MappingNode, Tag: "!!map"}
+			}
+			mappingSet(resource, "labels", withLabels(mappingGet(resource, "labels"), labels))
+		}
+	}
+}
+
+// withLabels returns the labels (a mapping or a list of key=value) with the common labels added
+func withLabels(node *yaml3.Node, labels map[string]string) *yaml3.Node {
+	result := &yaml3.Node{Kind: yaml3.MappingNode, Tag: "!!map"}
+
+	if node != nil {
+		switch node.Kind {
+		case yaml3.MappingNode:
+			result.Content = node.Content
+		case yaml3.SequenceNode:
+			for _, item := range node.Content {
+				key, value, _ := strings.Cut(item.Value, "=")
+				mappingSet(result, key, scalar(value))
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if mappingGet(result, key) == nil {
+			mappingSet(result, key, scalar(labels[key]))
+		}
+	}
+
+	return result
+}
+
+func configTarget(name string) string {
+	return "/" + name
+}
+
+// secretTarget is relative, so the secret is in /run/secrets
+func secretTarget(name string) string {
+	return name
+}
+
+func scalar(value string) *yaml3.Node {
+	return &yaml3.Node{Kind: yaml3.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// mappingGet returns the value of the key in the mapping node, or nil
+func mappingGet(node *yaml3.Node, key string) *yaml3.Node {
+	if node == nil || node.Kind != yaml3.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// mappingSet sets the value of the key in the mapping node, it is added at the end if missing
+func mappingSet(node *yaml3.Node, key string, value *yaml3.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, scalar(key), value)
+}
+
+func mappingValues(node *yaml3.Node) []*yaml3.Node {
+	if node == nil || node.Kind != yaml3.MappingNode {
+		return nil
+	}
+
+	values := make([]*yaml3.Node, 0, len(node.Content)/2)
+	for i := 1; i < len(node.Content); i += 2 {
+		values = append(values, node.Content[i])
+	}
+	return values
+}
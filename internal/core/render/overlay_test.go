@@ -0,0 +1,285 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/meltred/meltcd/spec"
+)
+
+func overlays() fstest.MapFS {
+	return fstest.MapFS{
+		"base/compose.yaml": &fstest.MapFile{Data: []byte(`services:
+  web:
+    image: nginx:1.25
+    networks: [front]
+    volumes:
+      - data:/usr/share/nginx/html
+      - ./conf:/etc/nginx/conf.d
+    configs: [site]
+    secrets:
+      - source: token
+    ports:
+      - "80:80"
+  db:
+    image: localhost:5000/postgres:16
+    networks:
+      shared: {}
+    deploy:
+      labels: ["tier=db"]
+networks:
+  front: {}
+  shared:
+    external: true
+volumes:
+  data: {}
+configs:
+  site:
+    file: ./site.conf
+secrets:
+  token:
+    external: true
+`)},
+		"staging/overlay.yaml": &fstest.MapFile{Data: []byte(`base: ../base
+patchesStrategicMerge:
+  - replicas.yaml
+patchesJson6902:
+  - path: ports.yaml
+  - patch: |
+      - op: add
+        path: /services/web/environment
+        value: {STAGE: staging}
+images:
+  - name: nginx
+    newTag: "1.26"
+`)},
+		"staging/replicas.yaml": &fstest.MapFile{Data: []byte(`services:
+  web:
+    deploy:
+      replicas: 2
+`)},
+		"staging/ports.yaml": &fstest.MapFile{Data: []byte(`[{"op": "replace", "path": "/services/web/ports/0", "value": "8080:80"}]`)},
+		"prod/overlay.yaml": &fstest.MapFile{Data: []byte(`base: ../staging
+patchesJson6902:
+  - patch: |
+      - op: test
+        path: /services/web/deploy/replicas
+        value: 2
+      - op: replace
+        path: /services/web/deploy/replicas
+        value: 3
+      - op: remove
+        path: /services/web/environment/STAGE
+images:
+  - name: localhost:5000/postgres
+    newName: registry.example.com/postgres
+namePrefix: prod-
+commonLabels:
+  env: prod
+`)},
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	rendered, err := Overlay(overlays(), "staging", OverlayOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := spec.Load(rendered, nil, "base", nil)
+	if err != nil {
+		t.Fatalf("rendered service file is not valid: %s\n%s", err.Error(), rendered)
+	}
+
+	web := d.Services["web"]
+	if web.Image != "nginx:1.26" {
+		t.Errorf("image = %q, want nginx:1.26", web.Image)
+	}
+	if web.Deploy.Replicas == nil || *web.Deploy.Replicas != 2 {
+		t.Errorf("replicas = %v, want 2", web.Deploy.Replicas)
+	}
+	if len(web.Ports) != 1 || web.Ports[0].Published != "8080" {
+		t.Errorf("ports = %+v, want 8080:80", web.Ports)
+	}
+	if web.Environment["STAGE"] != "staging" {
+		t.Errorf("environment = %v", web.Environment)
+	}
+
+	dir, err := OverlayBaseDir(overlays(), "staging")
+	if err != nil || dir != "base" {
+		t.Errorf("base dir = %q (%v), want base", dir, err)
+	}
+}
+
+func TestOverlayNamePrefixAndLabels(t *testing.T) {
+	rendered, err := Overlay(overlays(), "prod", OverlayOptions{
+		Images: []Image{{Name: "nginx", NewTag: "1.27"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := spec.Load(rendered, nil, "base", nil)
+	if err != nil {
+		t.Fatalf("rendered service file is not valid: %s\n%s", err.Error(), rendered)
+	}
+
+	web, ok := d.Services["prod-web"]
+	if !ok {
+		t.Fatalf("services = %v, want prefixed names", d.Services)
+	}
+	if web.Image != "nginx:1.27" {
+		t.Errorf("image = %q, the option of the application must override the overlay", web.Image)
+	}
+	if web.Deploy.Replicas == nil || *web.Deploy.Replicas != 3 {
+		t.Errorf("replicas = %v, want 3", web.Deploy.Replicas)
+	}
+	if _, ok := web.Environment["STAGE"]; ok {
+		t.Errorf("environment = %v, STAGE must be removed", web.Environment)
+	}
+	if _, ok := web.Networks["prod-front"]; !ok {
+		t.Errorf("networks = %v, want prod-front", web.Networks)
+	}
+	if web.Volumes[0].Source != "prod-data" || web.Volumes[1].Source != "./conf" {
+		t.Errorf("volumes = %+v, only named volumes must be prefixed", web.Volumes)
+	}
+	if web.Configs[0].Source != "prod-site" || web.Configs[0].Target != "/site" {
+		t.Errorf("configs = %+v, the target must not change", web.Configs)
+	}
+	if web.Secrets[0].Source != "prod-token" || web.Secrets[0].Target != "token" {
+		t.Errorf("secrets = %+v, the target must not change", web.Secrets)
+	}
+	if web.Deploy.Labels["env"] != "prod" {
+		t.Errorf("labels = %v, want env=prod", web.Deploy.Labels)
+	}
+
+	db := d.Services["prod-db"]
+	if db.Image != "registry.example.com/postgres:16" {
+		t.Errorf("image = %q", db.Image)
+	}
+	if db.Deploy.Labels["tier"] != "db" || db.Deploy.Labels["env"] != "prod" {
+		t.Errorf("labels = %v", db.Deploy.Labels)
+	}
+	if _, ok := db.Networks["prod-shared"]; !ok {
+		t.Errorf("networks = %v, want prod-shared", db.Networks)
+	}
+
+	if shared := d.Networks["prod-shared"]; shared.Name != "shared" || len(shared.Labels) != 0 {
+		t.Errorf("external network = %+v, it must keep its name and labels", shared)
+	}
+	if d.Networks["prod-front"].Labels["env"] != "prod" || d.Volumes["prod-data"].Labels["env"] != "prod" {
+		t.Errorf("networks = %+v, volumes = %+v, want env=prod", d.Networks, d.Volumes)
+	}
+	if d.Secrets["prod-token"].Name != "token" {
+		t.Errorf("external secret = %+v, it must keep its name", d.Secrets["prod-token"])
+	}
+}
+
+func TestOverlayErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		overlay string
+		err     string
+	}{
+		{"missing base", "patchesStrategicMerge: [a.yaml]", "base is required"},
+		{"outside of repository", "base: ../../base", "outside of the repository"},
+		{"cycle", "base: .", "too many nested overlays"},
+		{"failed test", "base: ../base\npatchesJson6902:\n  - patch: '[{op: test, path: /services/web/image, value: httpd}]'", "value is not equal"},
+		{"missing path", "base: ../base\npatchesJson6902:\n  - patch: '[{op: remove, path: /services/api}]'", "key api is not found"},
+		{"unknown op", "base: ../base\npatchesJson6902:\n  - patch: '[{op: merge, path: /services}]'", "unsupported operation"},
+	}
+
+	for _, test := range tests {
+		files := overlays()
+		files["bad/overlay.yaml"] = &fstest.MapFile{Data: []byte(test.overlay)}
+
+		_, err := Overlay(files, "bad", OverlayOptions{})
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error %q, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	files := fstest.MapFS{
+		"base/compose.yaml": &fstest.MapFile{Data: []byte(`services:
+  web:
+    image: nginx
+    command: [nginx, -g, daemon off;]
+    labels:
+      a/b: x
+`)},
+		"o/overlay.yaml": &fstest.MapFile{Data: []byte(`base: ../base
+patchesJson6902:
+  - patch: |
+      - {op: add, path: /services/web/command/1, value: -c}
+      - {op: add, path: /services/web/command/-, value: last}
+      - {op: copy, from: /services/web, path: /services/worker}
+      - {op: move, from: /services/web/labels/a~1b, path: /services/web/labels/moved}
+`)},
+	}
+
+	rendered, err := Overlay(files, "o", OverlayOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := spec.Load(rendered, nil, "base", nil)
+	if err != nil {
+		t.Fatalf("%s\n%s", err.Error(), rendered)
+	}
+
+	web := d.Services["web"]
+	if got := strings.Join(web.Command, " "); got != "nginx -c -g daemon off; last" {
+		t.Errorf("command = %q", got)
+	}
+	if web.Labels["moved"] != "x" || len(web.Labels) != 1 {
+		t.Errorf("labels = %v", web.Labels)
+	}
+	if worker := d.Services["worker"]; worker.Image != "nginx" || len(worker.Labels) != 1 {
+		t.Errorf("worker = %+v, it must be a copy of web before the move", worker)
+	}
+}
+
+func TestParseImage(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Image
+		err  bool
+	}{
+		{"nginx:1.26", Image{Name: "nginx", NewTag: "1.26"}, false},
+		{"nginx=registry.io/nginx:1.26", Image{Name: "nginx", NewName: "registry.io/nginx", NewTag: "1.26"}, false},
+		{"localhost:5000/app@sha256:abc", Image{Name: "localhost:5000/app", Digest: "sha256:abc"}, false},
+		{"app=other", Image{Name: "app", NewName: "other"}, false},
+		{"nginx", Image{}, true},
+		{"=nginx:1", Image{}, true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseImage(test.in)
+		if (err != nil) != test.err {
+			t.Errorf("ParseImage(%q) error = %v", test.in, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseImage(%q) = %+v, want %+v", test.in, got, test.want)
+		}
+	}
+}
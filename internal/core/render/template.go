@@ -0,0 +1,235 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package render renders the service file of the sources which are not
+// plain compose files, like a directory of templates
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/meltred/meltcd/spec"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// DefaultValuesFile is the values file used when no value files are specified
+const DefaultValuesFile = "values.yaml"
+
+// TemplatesDir is the directory of the templates in the template directory
+const TemplatesDir = "templates"
+
+// TemplateOptions are the values of a template source
+type TemplateOptions struct {
+	AppName    string
+	ValueFiles []string          // relative to the template directory, values.yaml by default
+	Set        map[string]string // values like "image.tag": "1.4.2", they override the value files
+}
+
+// Template renders the templates of the directory dir, like a helm chart: the templates are the
+// yaml files in dir/templates rendered with the values of dir/values.yaml (or the value files),
+// files starting with "_" only define named templates for include, the rendered files are merged
+// in the order of their names
+func Template(files fs.FS, dir string, opts TemplateOptions) ([]byte, error) {
+	values, err := loadValues(files, dir, opts.ValueFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range opts.Set {
+		if err := setValue(values, key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	templatesDir := path.Join(dir, TemplatesDir)
+	entries, err := fs.ReadDir(files, templatesDir)
+	if err != nil {
+		return nil, fmt.Errorf("templates directory %s: %w", templatesDir, err)
+	}
+
+	root := template.New(dir)
+	root.Funcs(funcMap(root))
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !isTemplateFile(name) {
+			continue
+		}
+
+		content, err := fs.ReadFile(files, path.Join(templatesDir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := root.New(name).Parse(string(content)); err != nil {
+			return nil, err
+		}
+
+		if !strings.HasPrefix(name, "_") {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no templates found in %s", templatesDir)
+	}
+	sort.Strings(names)
+
+	data := map[string]interface{}{
+		"Values": values,
+		"App": map[string]interface{}{
+			"Name": opts.AppName,
+		},
+	}
+
+	var rendered [][]byte
+	for _, name := range names {
+		var buf bytes.Buffer
+		if err := root.ExecuteTemplate(&buf, name, data); err != nil {
+			return nil, err
+		}
+
+		// like helm, a missing value is rendered as an empty string
+		out := strings.ReplaceAll(buf.String(), "<no value>", "")
+		if strings.TrimSpace(out) == "" {
+			continue
+		}
+		rendered = append(rendered, []byte(out))
+	}
+
+	if len(rendered) == 1 {
+		return rendered[0], nil
+	}
+
+	return spec.Merge(rendered...)
+}
+
+func isTemplateFile(name string) bool {
+	switch path.Ext(name) {
+	case ".yaml", ".yml", ".tpl":
+		return true
+	}
+	return false
+}
+
+// loadValues reads and merges the value files, a later file overrides the earlier ones
+func loadValues(files fs.FS, dir string, valueFiles []string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+
+	required := true
+	if len(valueFiles) == 0 {
+		valueFiles = []string{DefaultValuesFile}
+		required = false
+	}
+
+	for _, name := range valueFiles {
+		data, err := readFile(files, dir, name)
+		if errors.Is(err, fs.ErrNotExist) && !required {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("values file %s: %w", name, err)
+		}
+
+		var fileValues map[string]interface{}
+		if err := yaml3.Unmarshal(data, &fileValues); err != nil {
+			return nil, fmt.Errorf("values file %s: %w", name, err)
+		}
+
+		mergeValues(values, fileValues)
+	}
+
+	return values, nil
+}
+
+// readFile reads the file relative to dir, it can not be outside of the repository
+func readFile(files fs.FS, dir string, name string) ([]byte, error) {
+	p, err := repoPath(dir, name)
+	if err != nil {
+		return nil, fmt.Errorf("can not read %s, %w", name, err)
+	}
+
+	return fs.ReadFile(files, p)
+}
+
+// repoPath joins the relative path name to dir, it must stay inside the repository
+func repoPath(dir string, name string) (string, error) {
+	if path.IsAbs(name) {
+		return "", errors.New("path must be relative")
+	}
+
+	p := path.Join(dir, name)
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return "", errors.New("path is outside of the repository")
+	}
+
+	return p, nil
+}
+
+// mergeValues merges the override into values, maps are merged and other values replaced
+func mergeValues(values map[string]interface{}, override map[string]interface{}) {
+	for key, value := range override {
+		overrideMap, ok := value.(map[string]interface{})
+		existing, existingOk := values[key].(map[string]interface{})
+		if ok && existingOk {
+			mergeValues(existing, overrideMap)
+			continue
+		}
+		values[key] = value
+	}
+}
+
+// setValue sets the value at the dotted path (like "image.tag"), the value is parsed
+// as a yaml scalar, so "3" is a number and "true" is a boolean
+func setValue(values map[string]interface{}, key string, value string) error {
+	parts := strings.Split(key, ".")
+	for _, part := range parts {
+		if part == "" {
+			return fmt.Errorf("invalid value name %q", key)
+		}
+	}
+
+	var parsed interface{}
+	if err := yaml3.Unmarshal([]byte(value), &parsed); err != nil {
+		parsed = value
+	}
+	switch parsed.(type) {
+	case nil, map[string]interface{}, []interface{}:
+		// only scalars are parsed, like "a: b" is a string
+		parsed = value
+	}
+
+	current := values
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			current[part] = next
+		}
+		current = next
+	}
+	current[parts[len(parts)-1]] = parsed
+
+	return nil
+}
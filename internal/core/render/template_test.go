@@ -0,0 +1,188 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/meltred/meltcd/spec"
+)
+
+func chart() fstest.MapFS {
+	return fstest.MapFS{
+		"stack/values.yaml": &fstest.MapFile{Data: []byte(`
+image:
+  repository: nginx
+  tag: "1.25"
+replicas: 1
+env:
+  LOG_LEVEL: info
+worker:
+  enabled: false
+`)},
+		"stack/values-prod.yaml": &fstest.MapFile{Data: []byte(`
+replicas: 3
+env:
+  LOG_LEVEL: warn
+worker:
+  enabled: true
+`)},
+		"stack/templates/_helpers.tpl": &fstest.MapFile{Data: []byte(`{{- define "image" -}}
+{{ .Values.image.repository }}:{{ .Values.image.tag | default "latest" }}
+{{- end -}}`)},
+		"stack/templates/web.yaml": &fstest.MapFile{Data: []byte(`services:
+  web:
+    image: {{ include "image" . | quote }}
+    hostname: {{ .App.Name }}-web
+    deploy:
+      replicas: {{ .Values.replicas }}
+    environment:
+      {{- toYaml .Values.env | nindent 6 }}
+      MISSING: "{{ .Values.missing }}"
+`)},
+		"stack/templates/worker.yaml": &fstest.MapFile{Data: []byte(`{{- if .Values.worker.enabled }}
+services:
+  worker:
+    image: {{ include "image" . }}
+    command: ["worker", "--threads", "{{ mul .Values.replicas 2 }}"]
+{{- end }}
+`)},
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	rendered, err := Template(chart(), "stack", TemplateOptions{AppName: "shop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := spec.Load(rendered, nil, "stack", nil)
+	if err != nil {
+		t.Fatalf("rendered service file is not valid: %s\n%s", err.Error(), rendered)
+	}
+
+	web := d.Services["web"]
+	if web.Image != "nginx:1.25" || web.Hostname != "shop-web" {
+		t.Errorf("web = %+v", web)
+	}
+	if web.Deploy.Replicas == nil || *web.Deploy.Replicas != 1 {
+		t.Errorf("replicas = %v, want 1", web.Deploy.Replicas)
+	}
+	if web.Environment["LOG_LEVEL"] != "info" || web.Environment["MISSING"] != "" {
+		t.Errorf("environment = %v", web.Environment)
+	}
+	if _, ok := d.Services["worker"]; ok {
+		t.Error("worker is disabled, it must not be rendered")
+	}
+}
+
+func TestTemplateOverrides(t *testing.T) {
+	rendered, err := Template(chart(), "stack", TemplateOptions{
+		AppName:    "shop",
+		ValueFiles: []string{"values.yaml", "values-prod.yaml"},
+		Set:        map[string]string{"image.tag": "1.26", "replicas": "5"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := spec.Load(rendered, nil, "stack", nil)
+	if err != nil {
+		t.Fatalf("rendered service file is not valid: %s\n%s", err.Error(), rendered)
+	}
+
+	web := d.Services["web"]
+	if web.Image != "nginx:1.26" {
+		t.Errorf("image = %q, want nginx:1.26", web.Image)
+	}
+	if web.Deploy.Replicas == nil || *web.Deploy.Replicas != 5 {
+		t.Errorf("replicas = %v, want 5", web.Deploy.Replicas)
+	}
+	if web.Environment["LOG_LEVEL"] != "warn" {
+		t.Errorf("environment = %v", web.Environment)
+	}
+
+	worker, ok := d.Services["worker"]
+	if !ok {
+		t.Fatal("worker is enabled in values-prod.yaml, it must be rendered")
+	}
+	if strings.Join(worker.Command, " ") != "worker --threads 10" {
+		t.Errorf("command = %v", worker.Command)
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	files := chart()
+	files["stack/templates/bad.yaml"] = &fstest.MapFile{Data: []byte(`{{ required "secret is required" .Values.secret }}`)}
+
+	if _, err := Template(files, "stack", TemplateOptions{}); err == nil || !strings.Contains(err.Error(), "secret is required") {
+		t.Errorf("expected required error, got %v", err)
+	}
+
+	if _, err := Template(chart(), "stack", TemplateOptions{ValueFiles: []string{"missing.yaml"}}); err == nil {
+		t.Error("expected error for missing values file")
+	}
+
+	if _, err := Template(chart(), "stack", TemplateOptions{ValueFiles: []string{"../../etc/values.yaml"}}); err == nil {
+		t.Error("expected error for values file outside of the repository")
+	}
+
+	if _, err := Template(chart(), "other", TemplateOptions{}); err == nil {
+		t.Error("expected error for missing templates directory")
+	}
+}
+
+func TestFuncs(t *testing.T) {
+	files := fstest.MapFS{
+		"templates/t.yaml": &fstest.MapFile{Data: []byte(`services:
+  s:
+    image: {{ list "a" "b" | join "," | upper }}
+    hostname: {{ "Hello" | trimSuffix "o" | lower }}{{ ternary "-x" "-y" true }}
+    labels:
+      sum: "{{ add 1 2 }}"
+      coalesce: {{ coalesce "" .Values.none "first" | squote }}
+      b64: {{ "meltcd" | b64enc }}
+      keys: "{{ keys (dict "b" 1 "a" 2) | join "," }}"
+      tpl: "{{ tpl "{{ .Values.name }}" . }}"
+`)},
+		"values.yaml": &fstest.MapFile{Data: []byte("name: from-values\n")},
+	}
+
+	rendered, err := Template(files, ".", TemplateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := spec.Load(rendered, nil, ".", nil)
+	if err != nil {
+		t.Fatalf("%s\n%s", err.Error(), rendered)
+	}
+
+	s := d.Services["s"]
+	if s.Image != "A,B" || s.Hostname != "hell-x" {
+		t.Errorf("service = %+v", s)
+	}
+
+	want := spec.Mapping{"sum": "3", "coalesce": "first", "b64": "bWVsdGNk", "keys": "a,b", "tpl": "from-values"}
+	for k, v := range want {
+		if s.Labels[k] != v {
+			t.Errorf("label %s = %q, want %q", k, s.Labels[k], v)
+		}
+	}
+}
@@ -20,10 +20,13 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strings"
 
 	"log/slog"
 
+	"github.com/meltred/meltcd/internal/core/application"
 	"github.com/meltred/meltcd/internal/core/auth"
+	"github.com/meltred/meltcd/internal/core/gitcache"
 	"github.com/meltred/meltcd/internal/core/repository"
 )
 
@@ -33,6 +36,8 @@ const MELTCD_REPOSITORY_FILE = "repositories.json"   //nolint
 const MELTCD_AUTH_FILE = "auth.json"                 //nolint
 const MELTCD_ACCESS_TOKEN = "access_token.txt"       //nolint
 const MELTCD_LOG_FILE = "general.log"                //nolint
+const MELTCD_GIT_CACHE_DIR = "git"                   //nolint
+const MELTCD_HISTORY_DIR = "history"                 //nolint
 
 // Setup will setup require
 // settings to make use of MeltCD
@@ -52,6 +57,14 @@ func meltcdState() error {
 	authFile := getAuthFile()
 	accessTokenFile := getAccessTokenFile()
 
+	if err := gitcache.Setup(getGitCacheDir()); err != nil {
+		return err
+	}
+
+	if err := application.SetupHistory(path.Join(getMeltcdDir(), MELTCD_HISTORY_DIR)); err != nil {
+		return err
+	}
+
 	// When creating a fresh auth file (db) insert admin:admin username and password
 	_, err := os.Stat(authFile)
 	if err != nil {
@@ -198,6 +211,17 @@ func getAccessTokenFile() string {
 	return path.Join(meltcdDir, MELTCD_ACCESS_TOKEN)
 }
 
+// getGitCacheDir is where the git repositories are cloned,
+// it can be moved to a volume using MELTCD_GIT_CACHE env var
+func getGitCacheDir() string {
+	if dir := strings.TrimSpace(os.Getenv("MELTCD_GIT_CACHE")); dir != "" {
+		return dir
+	}
+
+	meltcdDir := getMeltcdDir()
+	return path.Join(meltcdDir, MELTCD_GIT_CACHE_DIR)
+}
+
 func getLogFile() string {
 	meltcdDir := getMeltcdDir()
 	return path.Join(meltcdDir, MELTCD_LOG_FILE)
@@ -0,0 +1,121 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"net/url"
+	"strings"
+
+	"log/slog"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/meltred/meltcd/internal/core/application"
+	"github.com/meltred/meltcd/internal/core/gitcache"
+)
+
+// PushEvent is a push to a git repository received from a webhook
+type PushEvent struct {
+	Provider      string   // github, gitlab, gitea, bitbucket or generic
+	RepoURLs      []string // urls of the pushed repository (https, ssh and web url)
+	Ref           string   // pushed ref like "refs/heads/main" or "refs/tags/v1.0.0"
+	DefaultBranch string   // default branch of the repository, empty if not known
+}
+
+// HandlePush syncs the applications whose source is the pushed repository and revision,
+// it returns the names of the applications which are synced
+func HandlePush(event PushEvent) []string {
+	repos := map[string]bool{}
+	for _, u := range event.RepoURLs {
+		if key := repoKey(u); key != "" {
+			repos[key] = true
+		}
+	}
+
+	var synced []string
+	for _, app := range apps() {
+		if !repos[repoKey(app.Source.RepoURL)] || !revisionMatches(app.Source.TargetRevision, event.Ref, event.DefaultBranch) {
+			continue
+		}
+
+		// the repository can be fetched just before the push,
+		// so the cached clone is not used for the next sync
+		gitcache.Get(app.Source.RepoURL).Invalidate()
+
+		if !app.TrySync(application.WebhookSync, event.Provider) {
+			slog.Info("Sync is already pending", "app_name", app.Name)
+		}
+		synced = append(synced, app.Name)
+	}
+
+	slog.Info("Received push webhook", "provider", event.Provider, "ref", event.Ref, "apps", synced)
+	return synced
+}
+
+// repoKey is the host and path of the repository url in lower case,
+// so the https, ssh ("git@host:owner/repo.git") and web urls of a repository are same
+func repoKey(repoURL string) string {
+	repoURL = strings.TrimSpace(repoURL)
+
+	if !strings.Contains(repoURL, "://") {
+		// scp like ssh url: git@github.com:owner/repo.git
+		if user, rest, ok := strings.Cut(repoURL, "@"); ok && !strings.Contains(user, "/") {
+			repoURL = rest
+		}
+		repoURL = "ssh://" + strings.Replace(repoURL, ":", "/", 1)
+	}
+
+	u, err := url.Parse(repoURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	p := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	return strings.ToLower(u.Hostname() + "/" + p)
+}
+
+// revisionMatches tells if an application with the target revision
+// needs to be synced when ref is pushed
+func revisionMatches(revision, ref, defaultBranch string) bool {
+	revision = strings.TrimSpace(revision)
+
+	if branch, ok := strings.CutPrefix(ref, "refs/heads/"); ok {
+		switch revision {
+		case "", "HEAD":
+			// when the default branch is not known, syncing is harmless
+			return defaultBranch == "" || branch == defaultBranch
+		case branch, ref:
+			return true
+		}
+		return false
+	}
+
+	if tag, ok := strings.CutPrefix(ref, "refs/tags/"); ok {
+		if revision == tag || revision == ref {
+			return true
+		}
+
+		// semver constraint like "v1.2.*", a new tag can be the latest matching one
+		constraint, err := semver.NewConstraint(revision)
+		if err != nil {
+			return false
+		}
+		version, err := semver.NewVersion(tag)
+		return err == nil && constraint.Check(version)
+	}
+
+	return revision == ref
+}
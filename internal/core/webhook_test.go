@@ -0,0 +1,72 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import "testing"
+
+func TestRepoKey(t *testing.T) {
+	want := "github.com/meltred/meltcd"
+
+	urls := []string{
+		"https://github.com/meltred/meltcd",
+		"https://github.com/meltred/meltcd.git",
+		"https://github.com/Meltred/MeltCD/",
+		"http://user@github.com/meltred/meltcd.git",
+		"git@github.com:meltred/meltcd.git",
+		"ssh://git@github.com/meltred/meltcd.git",
+		"ssh://git@github.com:22/meltred/meltcd",
+	}
+
+	for _, u := range urls {
+		if got := repoKey(u); got != want {
+			t.Errorf("repoKey(%q) = %q, want %q", u, got, want)
+		}
+	}
+
+	if got := repoKey("https://gitlab.com/meltred/meltcd"); got == want {
+		t.Errorf("repoKey of a different host should not match")
+	}
+}
+
+func TestRevisionMatches(t *testing.T) {
+	cases := []struct {
+		revision      string
+		ref           string
+		defaultBranch string
+		want          bool
+	}{
+		{"HEAD", "refs/heads/main", "main", true},
+		{"", "refs/heads/main", "main", true},
+		{"HEAD", "refs/heads/feature", "main", false},
+		{"HEAD", "refs/heads/feature", "", true},
+		{"main", "refs/heads/main", "main", true},
+		{"refs/heads/main", "refs/heads/main", "", true},
+		{"main", "refs/heads/dev", "main", false},
+		{"v1.2.0", "refs/tags/v1.2.0", "", true},
+		{"v1.2.*", "refs/tags/v1.2.3", "", true},
+		{"v1.2.*", "refs/tags/v1.3.0", "", false},
+		{"main", "refs/tags/v1.2.0", "", false},
+		{"HEAD", "refs/tags/v1.2.0", "main", false},
+		{"refs/pull/1/head", "refs/pull/1/head", "", true},
+	}
+
+	for _, c := range cases {
+		if got := revisionMatches(c.revision, c.ref, c.defaultBranch); got != c.want {
+			t.Errorf("revisionMatches(%q, %q, %q) = %v, want %v", c.revision, c.ref, c.defaultBranch, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/meltred/meltcd/internal/core"
+)
+
+type DiffRequest struct {
+	Spec string `json:"spec"` // content of the service file
+}
+
+// Diff godoc
+//
+//	@summary	Get the difference between running services and the target state in git
+//	@tags		Apps
+//	@Security	ApiKeyAuth || cookies
+//	@param		app_name	path	string	true	"Application name"
+//	@produce	json
+//	@success	200	{object}	core.DiffResult
+//	@failure	500	{object}	GlobalResponse
+//	@router		/apps/{app_name}/diff [get]
+func Diff(c *fiber.Ctx) error {
+	appName := c.Params("app_name")
+
+	result, err := core.Diff(appName, "")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(GlobalResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// DiffWithSpec godoc
+//
+//	@summary	Get the difference between running services and a service file
+//	@tags		Apps
+//	@Security	ApiKeyAuth || cookies
+//	@accept		json
+//	@produce	json
+//	@param		app_name	path		string		true	"Application name"
+//	@param		request		body		DiffRequest	true	"Service file"
+//	@success	200			{object}	core.DiffResult
+//	@failure	400			{object}	GlobalResponse
+//	@failure	500			{object}	GlobalResponse
+//	@router		/apps/{app_name}/diff [post]
+func DiffWithSpec(c *fiber.Ctx) error {
+	appName := c.Params("app_name")
+
+	var req DiffRequest
+	if err := c.BodyParser(&req); err != nil || req.Spec == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(GlobalResponse{
+			Message: "Failed to parse request body, service file (spec) is required",
+		})
+	}
+
+	result, err := core.Diff(appName, req.Spec)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(GlobalResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/meltred/meltcd/internal/core"
+	"github.com/meltred/meltcd/internal/core/application"
+)
+
+type HistoryResponse struct {
+	Data []application.Revision `json:"data"`
+}
+
+// History godoc
+//
+//	@summary	Get the deployment history of an application, latest first
+//	@tags		Apps
+//	@Security	ApiKeyAuth || cookies
+//	@param		app_name	path	string	true	"Application name"
+//	@produce	json
+//	@success	200	{object}	HistoryResponse
+//	@failure	500	{object}	GlobalResponse
+//	@router		/apps/{app_name}/history [get]
+func History(c *fiber.Ctx) error {
+	appName := c.Params("app_name")
+
+	revisions, err := core.History(appName)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(GlobalResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(HistoryResponse{Data: revisions})
+}
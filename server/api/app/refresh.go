@@ -32,8 +32,9 @@ import (
 //	@router		/apps/{app_name}/refresh [post]
 func Refresh(c *fiber.Ctx) error {
 	appName := c.Params("app_name")
+	username, _ := c.Locals("username").(string)
 
-	if err := core.Refresh(appName); err != nil {
+	if err := core.Refresh(appName, username); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(GlobalResponse{
 			Message: err.Error(),
 		})
@@ -0,0 +1,62 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/meltred/meltcd/internal/core"
+)
+
+type RollbackRequest struct {
+	Revision int `json:"revision"` // 0 rolls back to the previous successful revision
+}
+
+// Rollback godoc
+//
+//	@summary	Rollback an application to a previous revision, auto sync is paused until the next explicit sync
+//	@tags		Apps
+//	@Security	ApiKeyAuth || cookies
+//	@accept		json
+//	@produce	json
+//	@param		app_name	path		string			true	"Application name"
+//	@param		request		body		RollbackRequest	false	"Revision"
+//	@success	200			{object}	application.Revision
+//	@failure	400			{object}	GlobalResponse
+//	@failure	500			{object}	GlobalResponse
+//	@router		/apps/{app_name}/rollback [post]
+func Rollback(c *fiber.Ctx) error {
+	appName := c.Params("app_name")
+	username, _ := c.Locals("username").(string)
+
+	var req RollbackRequest
+	if len(c.Body()) != 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(GlobalResponse{
+				Message: "Failed to parse request body",
+			})
+		}
+	}
+
+	rev, err := core.Rollback(appName, req.Revision, username)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(GlobalResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(rev)
+}
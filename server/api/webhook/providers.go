@@ -0,0 +1,298 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/meltred/meltcd/internal/core"
+)
+
+// repository is the part of the github and gitea push payload we use
+type repository struct {
+	CloneURL      string `json:"clone_url"`
+	SSHURL        string `json:"ssh_url"`
+	HTMLURL       string `json:"html_url"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+type pushPayload struct {
+	Ref        string     `json:"ref"`
+	Deleted    bool       `json:"deleted"`
+	Repository repository `json:"repository"`
+}
+
+func (p pushPayload) event(provider string) core.PushEvent {
+	return core.PushEvent{
+		Provider:      provider,
+		RepoURLs:      []string{p.Repository.CloneURL, p.Repository.SSHURL, p.Repository.HTMLURL},
+		Ref:           p.Ref,
+		DefaultBranch: p.Repository.DefaultBranch,
+	}
+}
+
+// GitHub godoc
+//
+//	@summary	Receive push webhook from GitHub, signed with X-Hub-Signature-256
+//	@tags		Webhook
+//	@accept		json
+//	@produce	json
+//	@success	200	{object}	Response
+//	@failure	400	{object}	app.GlobalResponse
+//	@failure	401	{object}	app.GlobalResponse
+//	@failure	403	{object}	app.GlobalResponse
+//	@router		/webhook/github [post]
+func GitHub(c *fiber.Ctx) error {
+	if ok, err := verify(c, "github", func(key string) bool {
+		return validHMAC(c.Body(), c.Get("X-Hub-Signature-256"), key)
+	}); !ok {
+		return err
+	}
+
+	if event := c.Get("X-GitHub-Event"); event != "push" {
+		return ignore(c, event)
+	}
+
+	return handlePush(c, "github")
+}
+
+// Gitea godoc
+//
+//	@summary	Receive push webhook from Gitea (or Forgejo), signed with X-Gitea-Signature
+//	@tags		Webhook
+//	@accept		json
+//	@produce	json
+//	@success	200	{object}	Response
+//	@failure	400	{object}	app.GlobalResponse
+//	@failure	401	{object}	app.GlobalResponse
+//	@failure	403	{object}	app.GlobalResponse
+//	@router		/webhook/gitea [post]
+func Gitea(c *fiber.Ctx) error {
+	if ok, err := verify(c, "gitea", func(key string) bool {
+		signature := c.Get("X-Gitea-Signature")
+		if signature == "" {
+			signature = c.Get("X-Forgejo-Signature")
+		}
+		return validHMAC(c.Body(), signature, key)
+	}); !ok {
+		return err
+	}
+
+	event := c.Get("X-Gitea-Event")
+	if event == "" {
+		event = c.Get("X-Forgejo-Event")
+	}
+	if event != "push" {
+		return ignore(c, event)
+	}
+
+	return handlePush(c, "gitea")
+}
+
+func handlePush(c *fiber.Ctx, provider string) error {
+	var payload pushPayload
+	if err := json.Unmarshal(c.Body(), &payload); err != nil {
+		return badRequest(c, err)
+	}
+
+	if payload.Deleted {
+		return ignore(c, "branch or tag deletion")
+	}
+
+	return syncApps(c, []core.PushEvent{payload.event(provider)})
+}
+
+type gitlabPayload struct {
+	ObjectKind string `json:"object_kind"`
+	Ref        string `json:"ref"`
+	After      string `json:"after"`
+	Project    struct {
+		GitHTTPURL    string `json:"git_http_url"`
+		GitSSHURL     string `json:"git_ssh_url"`
+		WebURL        string `json:"web_url"`
+		DefaultBranch string `json:"default_branch"`
+	} `json:"project"`
+}
+
+// GitLab godoc
+//
+//	@summary	Receive push and tag push webhook from GitLab, authenticated with X-Gitlab-Token
+//	@tags		Webhook
+//	@accept		json
+//	@produce	json
+//	@success	200	{object}	Response
+//	@failure	400	{object}	app.GlobalResponse
+//	@failure	401	{object}	app.GlobalResponse
+//	@failure	403	{object}	app.GlobalResponse
+//	@router		/webhook/gitlab [post]
+func GitLab(c *fiber.Ctx) error {
+	if ok, err := verify(c, "gitlab", func(key string) bool {
+		return validToken(c.Get("X-Gitlab-Token"), key)
+	}); !ok {
+		return err
+	}
+
+	var payload gitlabPayload
+	if err := json.Unmarshal(c.Body(), &payload); err != nil {
+		return badRequest(c, err)
+	}
+
+	if payload.ObjectKind != "push" && payload.ObjectKind != "tag_push" {
+		return ignore(c, payload.ObjectKind)
+	}
+
+	// after is all zeros when the branch or tag is deleted
+	if strings.Trim(payload.After, "0") == "" {
+		return ignore(c, "branch or tag deletion")
+	}
+
+	return syncApps(c, []core.PushEvent{{
+		Provider:      "gitlab",
+		RepoURLs:      []string{payload.Project.GitHTTPURL, payload.Project.GitSSHURL, payload.Project.WebURL},
+		Ref:           payload.Ref,
+		DefaultBranch: payload.Project.DefaultBranch,
+	}})
+}
+
+type bitbucketPayload struct {
+	Repository struct {
+		FullName string `json:"full_name"`
+		Links    struct {
+			HTML struct {
+				Href string `json:"href"`
+			} `json:"html"`
+		} `json:"links"`
+		MainBranch struct {
+			Name string `json:"name"`
+		} `json:"mainbranch"`
+	} `json:"repository"`
+	Push struct {
+		Changes []struct {
+			New *struct {
+				Type string `json:"type"` // branch or tag
+				Name string `json:"name"`
+			} `json:"new"` // nil when the branch or tag is deleted
+		} `json:"changes"`
+	} `json:"push"`
+}
+
+// Bitbucket godoc
+//
+//	@summary	Receive push webhook from Bitbucket Cloud, signed with X-Hub-Signature
+//	@tags		Webhook
+//	@accept		json
+//	@produce	json
+//	@success	200	{object}	Response
+//	@failure	400	{object}	app.GlobalResponse
+//	@failure	401	{object}	app.GlobalResponse
+//	@failure	403	{object}	app.GlobalResponse
+//	@router		/webhook/bitbucket [post]
+func Bitbucket(c *fiber.Ctx) error {
+	if ok, err := verify(c, "bitbucket", func(key string) bool {
+		return validHMAC(c.Body(), c.Get("X-Hub-Signature"), key)
+	}); !ok {
+		return err
+	}
+
+	if event := c.Get("X-Event-Key"); event != "repo:push" {
+		return ignore(c, event)
+	}
+
+	var payload bitbucketPayload
+	if err := json.Unmarshal(c.Body(), &payload); err != nil {
+		return badRequest(c, err)
+	}
+
+	urls := []string{payload.Repository.Links.HTML.Href}
+	if payload.Repository.FullName != "" {
+		urls = append(urls, "https://bitbucket.org/"+payload.Repository.FullName)
+	}
+
+	var events []core.PushEvent
+	for _, change := range payload.Push.Changes {
+		if change.New == nil {
+			continue
+		}
+
+		ref := "refs/heads/" + change.New.Name
+		if change.New.Type == "tag" {
+			ref = "refs/tags/" + change.New.Name
+		}
+
+		events = append(events, core.PushEvent{
+			Provider:      "bitbucket",
+			RepoURLs:      urls,
+			Ref:           ref,
+			DefaultBranch: payload.Repository.MainBranch.Name,
+		})
+	}
+
+	return syncApps(c, events)
+}
+
+// GenericPayload is the body of the generic webhook, for CI pipelines and
+// git servers without a dedicated endpoint
+type GenericPayload struct {
+	RepoURL string `json:"repo_url"`
+	Ref     string `json:"ref"` // like "refs/heads/main", a branch name is also accepted
+}
+
+// Generic godoc
+//
+//	@summary	Receive a push notification from any source, authenticated with X-Meltcd-Token or X-Hub-Signature-256
+//	@tags		Webhook
+//	@accept		json
+//	@produce	json
+//	@param		request	body		GenericPayload	true	"Pushed repository and ref"
+//	@success	200		{object}	Response
+//	@failure	400		{object}	app.GlobalResponse
+//	@failure	401		{object}	app.GlobalResponse
+//	@failure	403		{object}	app.GlobalResponse
+//	@router		/webhook/generic [post]
+func Generic(c *fiber.Ctx) error {
+	if ok, err := verify(c, "generic", func(key string) bool {
+		if token := c.Get("X-Meltcd-Token"); token != "" {
+			return validToken(token, key)
+		}
+		return validHMAC(c.Body(), c.Get("X-Hub-Signature-256"), key)
+	}); !ok {
+		return err
+	}
+
+	var payload GenericPayload
+	if err := json.Unmarshal(c.Body(), &payload); err != nil {
+		return badRequest(c, err)
+	}
+
+	if payload.RepoURL == "" || payload.Ref == "" {
+		return badRequest(c, errors.New("repo_url and ref are required"))
+	}
+
+	ref := payload.Ref
+	if !strings.HasPrefix(ref, "refs/") {
+		ref = "refs/heads/" + ref
+	}
+
+	return syncApps(c, []core.PushEvent{{
+		Provider: "generic",
+		RepoURLs: []string{payload.RepoURL},
+		Ref:      ref,
+	}})
+}
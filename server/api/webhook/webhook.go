@@ -0,0 +1,114 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhook receives push notifications from git providers
+// and syncs the applications of the pushed repository right away
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"os"
+	"strings"
+
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/meltred/meltcd/internal/core"
+	"github.com/meltred/meltcd/server/api/app"
+)
+
+// Response lists the applications which are synced by the push
+type Response struct {
+	Message string   `json:"message"`
+	Apps    []string `json:"apps"`
+}
+
+// secret is shared with the git provider, it is used as the HMAC key
+// of the payload signature (or compared with the token for gitlab)
+func secret() string {
+	return strings.TrimSpace(os.Getenv("MELTCD_WEBHOOK_SECRET"))
+}
+
+// validHMAC checks the hex encoded HMAC-SHA256 signature of the body,
+// the signature may be prefixed with "sha256="
+func validHMAC(body []byte, signature string, key string) bool {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(body)
+
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func validToken(token string, key string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(key)) == 1
+}
+
+// verify checks the request with verifyFn, it fails when no secret is configured
+// since anyone would be able to trigger syncs otherwise. When the request is not verified
+// the response is already written, so the handler must return (with the error of writing it)
+func verify(c *fiber.Ctx, provider string, verifyFn func(key string) bool) (bool, error) {
+	key := secret()
+	if key == "" {
+		slog.Warn("Webhook received but MELTCD_WEBHOOK_SECRET is not set", "provider", provider)
+		return false, c.Status(fiber.StatusForbidden).JSON(app.GlobalResponse{
+			Message: "webhook secret is not configured, set MELTCD_WEBHOOK_SECRET on the server",
+		})
+	}
+
+	if !verifyFn(key) {
+		slog.Warn("Webhook with invalid signature", "provider", provider, "ip", c.IP())
+		return false, c.Status(fiber.StatusUnauthorized).JSON(app.GlobalResponse{
+			Message: "invalid webhook signature or token",
+		})
+	}
+
+	return true, nil
+}
+
+// syncApps handles the push events and responds with the synced applications
+func syncApps(c *fiber.Ctx, events []core.PushEvent) error {
+	apps := []string{}
+	for _, event := range events {
+		apps = append(apps, core.HandlePush(event)...)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(Response{
+		Message: "ok",
+		Apps:    apps,
+	})
+}
+
+func ignore(c *fiber.Ctx, event string) error {
+	return c.Status(fiber.StatusOK).JSON(Response{
+		Message: "ignored event " + event,
+		Apps:    []string{},
+	})
+}
+
+func badRequest(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusBadRequest).JSON(app.GlobalResponse{
+		Message: "Failed to parse webhook payload: " + err.Error(),
+	})
+}
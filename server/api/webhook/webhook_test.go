@@ -0,0 +1,100 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func signature(body []byte, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerify(t *testing.T) {
+	push := []byte(`{"ref":"refs/heads/main","repository":{"clone_url":"https://example.com/owner/repo.git"}}`)
+	gitlab := []byte(`{"object_kind":"push","ref":"refs/heads/main","after":"1a2b3c","project":{"git_http_url":"https://example.com/owner/repo.git"}}`)
+	bitbucket := []byte(`{"repository":{"full_name":"owner/repo"},"push":{"changes":[{"new":{"type":"branch","name":"main"}}]}}`)
+	generic := []byte(`{"repo_url":"https://example.com/owner/repo.git","ref":"main"}`)
+
+	cases := []struct {
+		provider string
+		handler  fiber.Handler
+		body     []byte
+		headers  func(body []byte, key string) map[string]string
+	}{
+		{"github", GitHub, push, func(body []byte, key string) map[string]string {
+			return map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": signature(body, key)}
+		}},
+		{"gitea", Gitea, push, func(body []byte, key string) map[string]string {
+			return map[string]string{"X-Gitea-Event": "push", "X-Gitea-Signature": signature(body, key)}
+		}},
+		{"gitlab", GitLab, gitlab, func(_ []byte, key string) map[string]string {
+			return map[string]string{"X-Gitlab-Token": key}
+		}},
+		{"bitbucket", Bitbucket, bitbucket, func(body []byte, key string) map[string]string {
+			return map[string]string{"X-Event-Key": "repo:push", "X-Hub-Signature": signature(body, key)}
+		}},
+		{"generic token", Generic, generic, func(_ []byte, key string) map[string]string {
+			return map[string]string{"X-Meltcd-Token": key}
+		}},
+		{"generic signature", Generic, generic, func(body []byte, key string) map[string]string {
+			return map[string]string{"X-Hub-Signature-256": signature(body, key)}
+		}},
+	}
+
+	for _, c := range cases {
+		send := func(key string) int {
+			app := fiber.New()
+			app.Post("/", c.handler)
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(c.body))
+			req.Header.Set("Content-Type", "application/json")
+			for k, v := range c.headers(c.body, key) {
+				req.Header.Set(k, v)
+			}
+
+			res, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return res.StatusCode
+		}
+
+		t.Setenv("MELTCD_WEBHOOK_SECRET", "")
+		if status := send("secret"); status != fiber.StatusForbidden {
+			t.Errorf("%s: status without a secret on the server = %d, want %d", c.provider, status, fiber.StatusForbidden)
+		}
+
+		t.Setenv("MELTCD_WEBHOOK_SECRET", "secret")
+		if status := send("wrong"); status != fiber.StatusUnauthorized {
+			t.Errorf("%s: status with a bad signature = %d, want %d", c.provider, status, fiber.StatusUnauthorized)
+		}
+		if status := send("secret"); status != fiber.StatusOK {
+			t.Errorf("%s: status with a valid signature = %d, want %d", c.provider, status, fiber.StatusOK)
+		}
+	}
+}
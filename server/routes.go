@@ -33,6 +33,7 @@ import (
 	Api "github.com/meltred/meltcd/server/api"
 	appApi "github.com/meltred/meltcd/server/api/app"
 	repoApi "github.com/meltred/meltcd/server/api/repo"
+	webhookApi "github.com/meltred/meltcd/server/api/webhook"
 	"github.com/meltred/meltcd/server/middleware"
 	"github.com/meltred/meltcd/version"
 
@@ -198,6 +199,18 @@ func Serve(ln net.Listener, origins string, verboseOutput bool) error {
 	apps.Put("/", appApi.Update)
 	apps.Post("/:app_name/refresh", appApi.Refresh)
 	apps.Post("/:app_name/recreate", appApi.Recreate)
+	apps.Get("/:app_name/diff", appApi.Diff)
+	apps.Post("/:app_name/diff", appApi.DiffWithSpec)
+	apps.Get("/:app_name/history", appApi.History)
+	apps.Post("/:app_name/rollback", appApi.Rollback)
+
+	// Git push notifications, authenticated with MELTCD_WEBHOOK_SECRET instead of a session
+	webhook := api.Group("webhook")
+	webhook.Post("/github", webhookApi.GitHub)
+	webhook.Post("/gitlab", webhookApi.GitLab)
+	webhook.Post("/gitea", webhookApi.Gitea)
+	webhook.Post("/bitbucket", webhookApi.Bitbucket)
+	webhook.Post("/generic", webhookApi.Generic)
 
 	repo := api.Group("repo", middleware.VerifyUser)
 	repo.Get("/", repoApi.List)
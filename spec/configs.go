@@ -0,0 +1,290 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// FileObject is a config or a secret of the service file
+type FileObject struct {
+	Name        string   `yaml:"name"`
+	File        string   `yaml:"file"`        // path in the repository, relative to the service file
+	Content     string   `yaml:"content"`     // inline content, configs only
+	Environment string   `yaml:"environment"` // environment variable of the meltcd server, it must be in MELTCD_ALLOWED_ENV
+	External    External `yaml:"external"`
+	Labels      Mapping  `yaml:"labels"`
+}
+
+// ServiceFileObject is a config or a secret used by a service,
+// written as the name (short syntax) or with the target file and permissions
+type ServiceFileObject struct {
+	Source string  `yaml:"source"`
+	Target string  `yaml:"target"`
+	UID    string  `yaml:"uid"`
+	GID    string  `yaml:"gid"`
+	Mode   *uint32 `yaml:"mode"`
+}
+
+func (o *ServiceFileObject) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var source string
+	if err := unmarshal(&source); err == nil {
+		*o = ServiceFileObject{Source: source}
+		return nil
+	}
+
+	type long ServiceFileObject
+	var l long
+	if err := unmarshal(&l); err != nil {
+		return err
+	}
+
+	if l.Source == "" {
+		return errors.New("source of the config or secret is required")
+	}
+
+	*o = ServiceFileObject(l)
+	return nil
+}
+
+// FileObjectData is a config or secret to create in docker
+type FileObjectData struct {
+	Name     string // docker name, "<app>_<name>_<content hash>" unless external
+	Data     []byte
+	Labels   map[string]string
+	External bool
+}
+
+// Ref is a docker config or secret
+type Ref struct {
+	ID   string
+	Name string
+}
+
+// ResourceRefs are the docker resources the services refer to,
+// keyed by their name in the service file
+type ResourceRefs struct {
+	Networks map[string]string // network id
+	Configs  map[string]Ref
+	Secrets  map[string]Ref
+}
+
+// UsedConfigs returns the configs used by the services, keyed by their name in the service file
+func (d *DockerSwarm) UsedConfigs(appName string) (map[string]FileObjectData, error) {
+	return d.usedFileObjects("config", appName, d.Configs, func(s Service) []ServiceFileObject { return s.Configs })
+}
+
+// UsedSecrets returns the secrets used by the services, keyed by their name in the service file
+func (d *DockerSwarm) UsedSecrets(appName string) (map[string]FileObjectData, error) {
+	return d.usedFileObjects("secret", appName, d.Secrets, func(s Service) []ServiceFileObject { return s.Secrets })
+}
+
+func (d *DockerSwarm) usedFileObjects(kind string, appName string, defined map[string]FileObject, refs func(Service) []ServiceFileObject) (map[string]FileObjectData, error) {
+	used := map[string]FileObjectData{}
+
+	for serviceName, service := range d.Services {
+		for _, ref := range refs(service) {
+			if _, ok := used[ref.Source]; ok {
+				continue
+			}
+
+			obj, ok := defined[ref.Source]
+			if !ok {
+				return nil, fmt.Errorf("service %s uses %s %s which is not defined in %ss", serviceName, kind, ref.Source, kind)
+			}
+
+			data, err := d.fileObjectData(kind, appName, ref.Source, obj)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", kind, ref.Source, err)
+			}
+
+			used[ref.Source] = data
+		}
+	}
+
+	return used, nil
+}
+
+func (d *DockerSwarm) fileObjectData(kind string, appName string, name string, obj FileObject) (FileObjectData, error) {
+	if obj.External.External {
+		dockerName := name
+		if obj.External.Name != "" {
+			dockerName = obj.External.Name
+		} else if obj.Name != "" {
+			dockerName = obj.Name
+		}
+		return FileObjectData{Name: dockerName, External: true}, nil
+	}
+
+	var data []byte
+	switch {
+	case obj.File != "":
+		content, err := d.ReadRepoFile(obj.File)
+		if err != nil {
+			return FileObjectData{}, err
+		}
+		data = content
+	case obj.Content != "" && kind == "config":
+		data = []byte(obj.Content)
+	case obj.Environment != "":
+		// anyone who can push to the repository can read the variable, so the server
+		// environment (which has secrets like MELTCD_WEBHOOK_SECRET) is not readable by default
+		if !allowedEnvironment(obj.Environment) {
+			return FileObjectData{}, fmt.Errorf("environment variable %s is not allowed, add it to MELTCD_ALLOWED_ENV on the server", obj.Environment)
+		}
+		value, ok := os.LookupEnv(obj.Environment)
+		if !ok {
+			return FileObjectData{}, fmt.Errorf("environment variable %s is not set", obj.Environment)
+		}
+		data = []byte(value)
+	default:
+		return FileObjectData{}, errors.New("one of file, content (configs only), environment or external is required")
+	}
+
+	// configs and secrets can not be updated, a new one is created
+	// when the content changes, which rolls the services using it
+	sum := sha256.Sum256(data)
+	base := obj.Name
+	if base == "" {
+		base = appName + "_" + name
+	}
+
+	labels := map[string]string{}
+	for k, v := range obj.Labels {
+		labels[k] = v
+	}
+
+	return FileObjectData{
+		Name:   base + "_" + hex.EncodeToString(sum[:5]),
+		Data:   data,
+		Labels: labels,
+	}, nil
+}
+
+// ReadRepoFile reads a file of the repository, name is relative to the directory of the service file
+func (d *DockerSwarm) ReadRepoFile(name string) ([]byte, error) {
+	if d.Files == nil {
+		return nil, fmt.Errorf("can not read %s, files of the repository are not available", name)
+	}
+
+	if path.IsAbs(name) {
+		return nil, fmt.Errorf("can not read %s, path must be relative to the service file", name)
+	}
+
+	p := path.Join(d.Dir, name)
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return nil, fmt.Errorf("can not read %s, path is outside of the repository", name)
+	}
+
+	return fs.ReadFile(d.Files, p)
+}
+
+func configReferences(objects []ServiceFileObject, refs map[string]Ref) ([]*swarm.ConfigReference, error) {
+	var result []*swarm.ConfigReference
+
+	for _, o := range objects {
+		ref, ok := refs[o.Source]
+		if !ok {
+			return nil, fmt.Errorf("config %s is not found", o.Source)
+		}
+
+		target := o.Target
+		if target == "" {
+			target = "/" + o.Source
+		}
+
+		result = append(result, &swarm.ConfigReference{
+			File:       fileTarget(target, o),
+			ConfigID:   ref.ID,
+			ConfigName: ref.Name,
+		})
+	}
+
+	return result, nil
+}
+
+func secretReferences(objects []ServiceFileObject, refs map[string]Ref) ([]*swarm.SecretReference, error) {
+	var result []*swarm.SecretReference
+
+	for _, o := range objects {
+		ref, ok := refs[o.Source]
+		if !ok {
+			return nil, fmt.Errorf("secret %s is not found", o.Source)
+		}
+
+		// relative targets are in /run/secrets
+		target := o.Target
+		if target == "" {
+			target = o.Source
+		}
+
+		file := fileTarget(target, o)
+		result = append(result, &swarm.SecretReference{
+			File: &swarm.SecretReferenceFileTarget{
+				Name: file.Name,
+				UID:  file.UID,
+				GID:  file.GID,
+				Mode: file.Mode,
+			},
+			SecretID:   ref.ID,
+			SecretName: ref.Name,
+		})
+	}
+
+	return result, nil
+}
+
+func fileTarget(target string, o ServiceFileObject) *swarm.ConfigReferenceFileTarget {
+	file := &swarm.ConfigReferenceFileTarget{
+		Name: target,
+		UID:  "0",
+		GID:  "0",
+		Mode: 0o444,
+	}
+
+	if o.UID != "" {
+		file.UID = o.UID
+	}
+	if o.GID != "" {
+		file.GID = o.GID
+	}
+	if o.Mode != nil {
+		file.Mode = fs.FileMode(*o.Mode)
+	}
+
+	return file
+}
+
+// allowedEnvironment tells if the environment variable of the server can be used by configs
+// and secrets, MELTCD_ALLOWED_ENV is the comma separated list of the allowed variables
+func allowedEnvironment(name string) bool {
+	for _, allowed := range strings.Split(os.Getenv("MELTCD_ALLOWED_ENV"), ",") {
+		if strings.TrimSpace(allowed) == name {
+			return true
+		}
+	}
+	return false
+}
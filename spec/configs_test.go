@@ -0,0 +1,174 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"gopkg.in/yaml.v2"
+)
+
+const configsFile = `
+services:
+  web:
+    image: nginx
+    configs:
+      - nginx_conf
+      - source: site
+        target: /etc/nginx/conf.d/site.conf
+        uid: "101"
+        mode: 0440
+    secrets:
+      - db_password
+      - source: tls
+        target: /run/tls/key.pem
+configs:
+  nginx_conf:
+    file: ./nginx.conf
+  site:
+    content: "server {}"
+  unused:
+    file: ./missing.conf
+secrets:
+  db_password:
+    file: secrets/db_password.txt
+  tls:
+    external: true
+    name: shared_tls
+`
+
+func TestConfigsAndSecrets(t *testing.T) {
+	var d DockerSwarm
+	if err := yaml.Unmarshal([]byte(configsFile), &d); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	d.Dir = "deploy"
+	d.Files = fstest.MapFS{
+		"deploy/nginx.conf":              {Data: []byte("events {}")},
+		"deploy/secrets/db_password.txt": {Data: []byte("hunter2")},
+	}
+
+	configs, err := d.UsedConfigs("app")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(configs) != 2 || string(configs["nginx_conf"].Data) != "events {}" || string(configs["site"].Data) != "server {}" {
+		t.Errorf("invalid configs %+v", configs)
+	}
+
+	if !strings.HasPrefix(configs["nginx_conf"].Name, "app_nginx_conf_") {
+		t.Errorf("config name should be prefixed with the app name, got %s", configs["nginx_conf"].Name)
+	}
+
+	secrets, err := d.UsedSecrets("app")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if string(secrets["db_password"].Data) != "hunter2" || !secrets["tls"].External || secrets["tls"].Name != "shared_tls" {
+		t.Errorf("invalid secrets %+v", secrets)
+	}
+
+	// changing the content changes the name, so the services are updated
+	before := configs["nginx_conf"].Name
+	d.Files.(fstest.MapFS)["deploy/nginx.conf"] = &fstest.MapFile{Data: []byte("events { worker_connections 512; }")}
+	configs, _ = d.UsedConfigs("app")
+	if configs["nginx_conf"].Name == before {
+		t.Error("config name should change with the content")
+	}
+
+	refs := ResourceRefs{
+		Networks: map[string]string{DefaultNetwork: "net"},
+		Configs:  map[string]Ref{"nginx_conf": {ID: "c1", Name: "app_nginx_conf_1"}, "site": {ID: "c2", Name: "app_site_2"}},
+		Secrets:  map[string]Ref{"db_password": {ID: "s1", Name: "app_db_password_1"}, "tls": {ID: "s2", Name: "shared_tls"}},
+	}
+
+	services, err := d.GetServiceSpec("app", refs)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	c := services[0].TaskTemplate.ContainerSpec
+	if len(c.Configs) != 2 || c.Configs[0].File.Name != "/nginx_conf" || c.Configs[0].ConfigID != "c1" ||
+		c.Configs[1].File.Name != "/etc/nginx/conf.d/site.conf" || c.Configs[1].File.UID != "101" || c.Configs[1].File.Mode != fs.FileMode(0o440) {
+		t.Errorf("invalid config references %+v %+v", c.Configs[0], c.Configs[1])
+	}
+
+	if len(c.Secrets) != 2 || c.Secrets[0].File.Name != "db_password" || c.Secrets[0].File.Mode != fs.FileMode(0o444) ||
+		c.Secrets[1].File.Name != "/run/tls/key.pem" || c.Secrets[1].SecretName != "shared_tls" {
+		t.Errorf("invalid secret references %+v %+v", c.Secrets[0], c.Secrets[1])
+	}
+}
+
+func TestInvalidConfigs(t *testing.T) {
+	invalid := []string{
+		"{services: {web: {configs: [missing]}}}",
+		"{services: {web: {configs: [c]}}, configs: {c: {file: ../outside.conf}}}",
+		"{services: {web: {configs: [c]}}, configs: {c: {file: /etc/passwd}}}",
+		"{services: {web: {configs: [c]}}, configs: {c: {file: not-found.conf}}}",
+		"{services: {web: {configs: [c]}}, configs: {c: {}}}",
+		"{services: {web: {secrets: [s]}}, secrets: {s: {content: inline}}}",
+	}
+
+	for _, data := range invalid {
+		var d DockerSwarm
+		if err := yaml.Unmarshal([]byte(data), &d); err != nil {
+			t.Errorf("failed to parse %s: %s", data, err.Error())
+			continue
+		}
+		d.Files = fstest.MapFS{}
+
+		_, configErr := d.UsedConfigs("app")
+		_, secretErr := d.UsedSecrets("app")
+		if configErr == nil && secretErr == nil {
+			t.Errorf("expected error for %s", data)
+		}
+	}
+}
+
+func TestEnvironmentSecret(t *testing.T) {
+	t.Setenv("MELTCD_WEBHOOK_SECRET", "server secret")
+	t.Setenv("DB_PASSWORD", "hunter2")
+	t.Setenv("MELTCD_ALLOWED_ENV", "OTHER, DB_PASSWORD")
+
+	var d DockerSwarm
+	data := "{services: {web: {secrets: [db, webhook]}}, secrets: {db: {environment: DB_PASSWORD}, webhook: {environment: MELTCD_WEBHOOK_SECRET}}}"
+	if err := yaml.Unmarshal([]byte(data), &d); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	_, err := d.UsedSecrets("app")
+	if err == nil || !strings.Contains(err.Error(), "MELTCD_WEBHOOK_SECRET is not allowed") {
+		t.Errorf("expected error for a variable which is not allowed, got %v", err)
+	}
+
+	delete(d.Secrets, "webhook")
+	d.Services["web"] = Service{Secrets: []ServiceFileObject{{Source: "db"}}}
+
+	secrets, err := d.UsedSecrets("app")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(secrets["db"].Data) != "hunter2" {
+		t.Errorf("invalid secret %+v", secrets["db"])
+	}
+}
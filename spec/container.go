@@ -0,0 +1,396 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/go-units"
+)
+
+// StringList is a string or a list of strings, like dns or tmpfs
+type StringList []string
+
+func (l *StringList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		*l = StringList{s}
+		return nil
+	}
+
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return errors.New("expected a string or a list of strings")
+	}
+
+	*l = list
+	return nil
+}
+
+// ShellCommand is a command written as a list or as a string,
+// which is split into words like a shell does (without expansions)
+type ShellCommand []string
+
+func (c *ShellCommand) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		words, err := splitShellWords(s)
+		if err != nil {
+			return err
+		}
+		*c = words
+		return nil
+	}
+
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return errors.New("command must be a string or a list of strings")
+	}
+
+	*c = list
+	return nil
+}
+
+// splitShellWords splits s on spaces, keeping quoted strings ('...' or "...") together
+func splitShellWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if r == '\\' && quote == '"' && i+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[i+1]) {
+				i++
+				word.WriteRune(runes[i])
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				word.WriteRune(runes[i])
+			}
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command %q", s)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// ExtraHosts is a list of "host:ip" (or "host=ip") or a map of host to ip
+type ExtraHosts []string
+
+func (e *ExtraHosts) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var list []string
+	if err := unmarshal(&list); err == nil {
+		*e = list
+		return nil
+	}
+
+	var hosts map[string]string
+	if err := unmarshal(&hosts); err != nil {
+		return errors.New("extra_hosts must be a list of host:ip or a map of host to ip")
+	}
+
+	result := make(ExtraHosts, 0, len(hosts))
+	for host, ip := range hosts {
+		result = append(result, host+"="+ip)
+	}
+	sort.Strings(result)
+
+	*e = result
+	return nil
+}
+
+type Healthcheck struct {
+	Test          ShellCommand `yaml:"test"`
+	Interval      string       `yaml:"interval"`
+	Timeout       string       `yaml:"timeout"`
+	Retries       int          `yaml:"retries"`
+	StartPeriod   string       `yaml:"start_period"`
+	StartInterval string       `yaml:"start_interval"` // not supported by the docker api version used
+	Disable       bool         `yaml:"disable"`
+}
+
+// UnmarshalYAML keeps the test string as a single shell command ("CMD-SHELL")
+func (h *Healthcheck) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain Healthcheck
+	var p plain
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+
+	var test struct {
+		Test interface{} `yaml:"test"`
+	}
+	if err := unmarshal(&test); err == nil {
+		if s, ok := test.Test.(string); ok {
+			p.Test = ShellCommand{"CMD-SHELL", s}
+		}
+	}
+
+	*h = Healthcheck(p)
+	return nil
+}
+
+type Ulimit struct {
+	Soft int64 `yaml:"soft"`
+	Hard int64 `yaml:"hard"`
+}
+
+// UnmarshalYAML accepts a single value used for both soft and hard limit
+func (u *Ulimit) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value int64
+	if err := unmarshal(&value); err == nil {
+		*u = Ulimit{Soft: value, Hard: value}
+		return nil
+	}
+
+	type plain Ulimit
+	var p plain
+	if err := unmarshal(&p); err != nil {
+		return errors.New("ulimit must be a number or {soft, hard}")
+	}
+
+	*u = Ulimit(p)
+	return nil
+}
+
+type Logging struct {
+	Driver  string            `yaml:"driver"`
+	Options map[string]string `yaml:"options"`
+}
+
+// applyContainer sets the container settings of the service (command, user, healthcheck...)
+func (s Service) applyContainer(targetSpec *swarm.ServiceSpec) error {
+	c := targetSpec.TaskTemplate.ContainerSpec
+
+	c.Command = s.Entrypoint
+	c.Args = s.Command
+	c.User = s.User
+	c.Dir = s.WorkingDir
+	c.Hostname = s.Hostname
+	c.Groups = s.GroupAdd
+	c.Init = s.Init
+	c.ReadOnly = s.ReadOnly
+	c.TTY = s.Tty
+	c.OpenStdin = s.StdinOpen
+	c.StopSignal = s.StopSignal
+	c.CapabilityAdd = s.CapAdd
+	c.CapabilityDrop = s.CapDrop
+
+	if len(s.Sysctls) != 0 {
+		c.Sysctls = s.Sysctls
+	}
+
+	for k, v := range s.Labels {
+		if _, ok := c.Labels[k]; !ok {
+			c.Labels[k] = v
+		}
+	}
+
+	stopGracePeriod, err := optionalDuration("stop_grace_period", s.StopGracePeriod)
+	if err != nil {
+		return err
+	}
+	c.StopGracePeriod = stopGracePeriod
+
+	if s.Healthcheck != nil {
+		healthcheck, err := s.Healthcheck.healthConfig()
+		if err != nil {
+			return fmt.Errorf("healthcheck: %w", err)
+		}
+		c.Healthcheck = healthcheck
+	}
+
+	hosts, err := extraHosts(s.ExtraHosts)
+	if err != nil {
+		return err
+	}
+	c.Hosts = hosts
+
+	if len(s.DNS) != 0 || len(s.DNSSearch) != 0 || len(s.DNSOpt) != 0 {
+		c.DNSConfig = &swarm.DNSConfig{
+			Nameservers: s.DNS,
+			Search:      s.DNSSearch,
+			Options:     s.DNSOpt,
+		}
+	}
+
+	names := make([]string, 0, len(s.Ulimits))
+	for name := range s.Ulimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		limit := s.Ulimits[name]
+		if limit.Soft > limit.Hard {
+			return fmt.Errorf("ulimit %s: soft limit is more than the hard limit", name)
+		}
+		c.Ulimits = append(c.Ulimits, &units.Ulimit{Name: name, Soft: limit.Soft, Hard: limit.Hard})
+	}
+
+	for _, t := range s.Tmpfs {
+		m, err := tmpfsMount(t)
+		if err != nil {
+			return err
+		}
+		c.Mounts = append(c.Mounts, m)
+	}
+
+	if s.Logging != nil && s.Logging.Driver != "" {
+		targetSpec.TaskTemplate.LogDriver = &swarm.Driver{
+			Name:    s.Logging.Driver,
+			Options: s.Logging.Options,
+		}
+	}
+
+	return nil
+}
+
+func (h Healthcheck) healthConfig() (*container.HealthConfig, error) {
+	if h.Disable {
+		return &container.HealthConfig{Test: []string{"NONE"}}, nil
+	}
+
+	config := container.HealthConfig{
+		Test:    h.Test,
+		Retries: h.Retries,
+	}
+
+	if len(config.Test) != 0 {
+		switch config.Test[0] {
+		case "NONE", "CMD", "CMD-SHELL":
+		default:
+			return nil, fmt.Errorf("test must start with NONE, CMD or CMD-SHELL, got %q", config.Test[0])
+		}
+	}
+
+	for _, d := range []struct {
+		name  string
+		value string
+		out   *time.Duration
+	}{
+		{"interval", h.Interval, &config.Interval},
+		{"timeout", h.Timeout, &config.Timeout},
+		{"start_period", h.StartPeriod, &config.StartPeriod},
+	} {
+		duration, err := optionalDuration(d.name, d.value)
+		if err != nil {
+			return nil, err
+		}
+		if duration != nil {
+			*d.out = *duration
+		}
+	}
+
+	return &config, nil
+}
+
+// extraHosts converts "host:ip" (or "host=ip") into the "ip host" format of swarm
+func extraHosts(hosts []string) ([]string, error) {
+	var result []string
+
+	for _, h := range hosts {
+		host, ip, ok := strings.Cut(h, "=")
+		if !ok {
+			host, ip, ok = strings.Cut(h, ":")
+		}
+		if !ok || host == "" || ip == "" {
+			return nil, fmt.Errorf("invalid extra_hosts %q, expected host:ip", h)
+		}
+
+		result = append(result, strings.Trim(ip, "[]")+" "+host)
+	}
+
+	return result, nil
+}
+
+// tmpfsMount parses "/path[:size=64m,mode=1777]"
+func tmpfsMount(s string) (mount.Mount, error) {
+	target, options, _ := strings.Cut(s, ":")
+	if !strings.HasPrefix(target, "/") {
+		return mount.Mount{}, fmt.Errorf("invalid tmpfs %q, path must be absolute", s)
+	}
+
+	m := mount.Mount{
+		Type:   mount.TypeTmpfs,
+		Target: target,
+	}
+
+	if options == "" {
+		return m, nil
+	}
+
+	m.TmpfsOptions = &mount.TmpfsOptions{}
+	for _, option := range strings.Split(options, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "size":
+			size, err := units.RAMInBytes(value)
+			if err != nil {
+				return mount.Mount{}, fmt.Errorf("invalid tmpfs size %q", value)
+			}
+			m.TmpfsOptions.SizeBytes = size
+		case "mode":
+			mode, err := strconv.ParseUint(value, 8, 32)
+			if err != nil {
+				return mount.Mount{}, fmt.Errorf("invalid tmpfs mode %q", value)
+			}
+			m.TmpfsOptions.Mode = os.FileMode(mode)
+		default:
+			return mount.Mount{}, fmt.Errorf("unsupported tmpfs option %q", option)
+		}
+	}
+
+	return m, nil
+}
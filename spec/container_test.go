@@ -0,0 +1,176 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/mount"
+	"gopkg.in/yaml.v2"
+)
+
+const containerFile = `
+services:
+  web:
+    image: nginx
+    build: .
+    container_name: web
+    x-custom: ignored
+    command: nginx -g "daemon off;"
+    entrypoint: ["/docker-entrypoint.sh"]
+    user: "101:101"
+    working_dir: /app
+    hostname: web-1
+    init: true
+    labels:
+      - team=web
+    healthcheck:
+      test: curl -f http://localhost
+      interval: 30s
+      timeout: 5s
+      retries: 3
+      start_period: 10s
+    stop_grace_period: 1m
+    stop_signal: SIGQUIT
+    cap_add: [NET_ADMIN]
+    cap_drop: [ALL]
+    sysctls:
+      net.core.somaxconn: 1024
+    ulimits:
+      nproc: 65535
+      nofile:
+        soft: 20000
+        hard: 40000
+    extra_hosts:
+      - "somehost:162.242.195.82"
+    dns: 8.8.8.8
+    logging:
+      driver: json-file
+      options:
+        max-size: 10m
+    tmpfs:
+      - /run
+      - /tmp:size=64m
+    deploy:
+      labels:
+        com.example.description: web server
+        com.docker.stack.namespace: other
+`
+
+func TestContainerFields(t *testing.T) {
+	var d DockerSwarm
+	if err := yaml.Unmarshal([]byte(containerFile), &d); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	services, err := d.GetServiceSpec("app", ResourceRefs{Networks: map[string]string{DefaultNetwork: "net"}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	s := services[0]
+	c := s.TaskTemplate.ContainerSpec
+
+	if !slices.Equal(c.Args, []string{"nginx", "-g", "daemon off;"}) || !slices.Equal(c.Command, []string{"/docker-entrypoint.sh"}) {
+		t.Errorf("invalid command %q entrypoint %q", c.Args, c.Command)
+	}
+
+	if c.User != "101:101" || c.Dir != "/app" || c.Hostname != "web-1" || c.Init == nil || !*c.Init {
+		t.Errorf("invalid container spec %+v", c)
+	}
+
+	if c.Labels["team"] != "web" || c.Labels["com.docker.stack.namespace"] != "app" {
+		t.Errorf("invalid container labels %v", c.Labels)
+	}
+
+	if s.Labels["com.example.description"] != "web server" || s.Labels["com.docker.stack.namespace"] != "app" {
+		t.Errorf("invalid service labels %v", s.Labels)
+	}
+
+	h := c.Healthcheck
+	if !slices.Equal(h.Test, []string{"CMD-SHELL", "curl -f http://localhost"}) || h.Interval != 30*time.Second ||
+		h.Timeout != 5*time.Second || h.Retries != 3 || h.StartPeriod != 10*time.Second {
+		t.Errorf("invalid healthcheck %+v", h)
+	}
+
+	if *c.StopGracePeriod != time.Minute || c.StopSignal != "SIGQUIT" {
+		t.Error("invalid stop settings")
+	}
+
+	if !slices.Equal(c.CapabilityAdd, []string{"NET_ADMIN"}) || !slices.Equal(c.CapabilityDrop, []string{"ALL"}) {
+		t.Error("invalid capabilities")
+	}
+
+	if c.Sysctls["net.core.somaxconn"] != "1024" {
+		t.Errorf("invalid sysctls %v", c.Sysctls)
+	}
+
+	if len(c.Ulimits) != 2 || c.Ulimits[0].Name != "nofile" || c.Ulimits[0].Soft != 20000 || c.Ulimits[0].Hard != 40000 ||
+		c.Ulimits[1].Name != "nproc" || c.Ulimits[1].Soft != 65535 {
+		t.Errorf("invalid ulimits %+v %+v", c.Ulimits[0], c.Ulimits[1])
+	}
+
+	if !slices.Equal(c.Hosts, []string{"162.242.195.82 somehost"}) {
+		t.Errorf("invalid hosts %v", c.Hosts)
+	}
+
+	if !slices.Equal(c.DNSConfig.Nameservers, []string{"8.8.8.8"}) {
+		t.Errorf("invalid dns %v", c.DNSConfig)
+	}
+
+	if s.TaskTemplate.LogDriver.Name != "json-file" || s.TaskTemplate.LogDriver.Options["max-size"] != "10m" {
+		t.Errorf("invalid logging %+v", s.TaskTemplate.LogDriver)
+	}
+
+	if len(c.Mounts) != 2 || c.Mounts[0].Type != mount.TypeTmpfs || c.Mounts[0].Target != "/run" ||
+		c.Mounts[1].TmpfsOptions.SizeBytes != 64*1024*1024 {
+		t.Errorf("invalid tmpfs mounts %+v", c.Mounts)
+	}
+
+	warnings := d.Warnings()
+	if len(warnings) != 2 {
+		t.Errorf("expected warnings for build and container_name, got %q", warnings)
+	}
+}
+
+func TestSplitShellWords(t *testing.T) {
+	cases := map[string][]string{
+		`echo hello`:             {"echo", "hello"},
+		`  echo   "a b"  'c d' `: {"echo", "a b", "c d"},
+		`sh -c "echo \"hi\""`:    {"sh", "-c", `echo "hi"`},
+		`a\ b`:                   {"a b"},
+		`printf '%s\n' x`:        {"printf", `%s\n`, "x"},
+		`""`:                     {""},
+	}
+
+	for s, want := range cases {
+		got, err := splitShellWords(s)
+		if err != nil {
+			t.Errorf("failed to split %s: %s", s, err.Error())
+			continue
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("splitShellWords(%s) = %q, want %q", s, got, want)
+		}
+	}
+
+	if _, err := splitShellWords(`echo "unterminated`); err == nil {
+		t.Error("expected error for unterminated quote")
+	}
+}
@@ -0,0 +1,286 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/go-units"
+)
+
+type Deploy struct {
+	Mode           string         `yaml:"mode"`     // replicated (default) or global
+	Replicas       *uint64        `yaml:"replicas"` // defaults to 1
+	Resources      Resources      `yaml:"resources"`
+	RestartPolicy  *RestartPolicy `yaml:"restart_policy"`
+	UpdateConfig   *UpdateConfig  `yaml:"update_config"`
+	RollbackConfig *UpdateConfig  `yaml:"rollback_config"`
+	Placement      Placement      `yaml:"placement"`
+	Labels         Mapping        `yaml:"labels"` // labels of the service
+}
+
+type Resources struct {
+	Limits       *Resource `yaml:"limits"`
+	Reservations *Resource `yaml:"reservations"`
+}
+
+// Resource is the cpus ("0.5") and memory ("512M") of the resource limits or reservations,
+// pids can only be limited
+type Resource struct {
+	Cpus   string `yaml:"cpus"`
+	Memory string `yaml:"memory"`
+	Pids   int64  `yaml:"pids"`
+}
+
+type RestartPolicy struct {
+	Condition   string  `yaml:"condition"` // none, on-failure or any
+	Delay       string  `yaml:"delay"`
+	MaxAttempts *uint64 `yaml:"max_attempts"`
+	Window      string  `yaml:"window"`
+}
+
+// UpdateConfig is the update_config and rollback_config of the service
+type UpdateConfig struct {
+	Parallelism     *uint64 `yaml:"parallelism"` // defaults to 1, 0 updates all tasks at once
+	Delay           string  `yaml:"delay"`
+	FailureAction   string  `yaml:"failure_action"` // continue, rollback or pause
+	Monitor         string  `yaml:"monitor"`
+	MaxFailureRatio float32 `yaml:"max_failure_ratio"`
+	Order           string  `yaml:"order"` // stop-first or start-first
+}
+
+// applyDeploy sets the mode, resources, restart policy, update and rollback config of the service
+func (d Deploy) applyDeploy(targetSpec *swarm.ServiceSpec) error {
+	switch d.Mode {
+	case "", "replicated":
+		replicas := uint64(1)
+		if d.Replicas != nil {
+			replicas = *d.Replicas
+		}
+		targetSpec.Mode.Replicated = &swarm.ReplicatedService{
+			Replicas: &replicas,
+		}
+	case "global":
+		if d.Replicas != nil {
+			return fmt.Errorf("replicas can not be set with global mode")
+		}
+		targetSpec.Mode.Global = &swarm.GlobalService{}
+	default:
+		return fmt.Errorf("invalid deploy mode %q, it must be replicated or global", d.Mode)
+	}
+
+	// labels set by meltcd (like the stack namespace) can not be changed
+	for k, v := range d.Labels {
+		if _, ok := targetSpec.Labels[k]; !ok {
+			targetSpec.Labels[k] = v
+		}
+	}
+
+	placement, err := d.Placement.swarmPlacement(targetSpec.Mode.Global != nil)
+	if err != nil {
+		return fmt.Errorf("placement: %w", err)
+	}
+	targetSpec.TaskTemplate.Placement = placement
+
+	resources, err := d.Resources.swarmResources()
+	if err != nil {
+		return err
+	}
+	targetSpec.TaskTemplate.Resources = resources
+
+	if d.RestartPolicy != nil {
+		policy, err := d.RestartPolicy.swarmRestartPolicy()
+		if err != nil {
+			return fmt.Errorf("restart_policy: %w", err)
+		}
+		targetSpec.TaskTemplate.RestartPolicy = policy
+	}
+
+	if d.UpdateConfig != nil {
+		config, err := d.UpdateConfig.swarmUpdateConfig()
+		if err != nil {
+			return fmt.Errorf("update_config: %w", err)
+		}
+		targetSpec.UpdateConfig = config
+	}
+
+	if d.RollbackConfig != nil {
+		config, err := d.RollbackConfig.swarmUpdateConfig()
+		if err != nil {
+			return fmt.Errorf("rollback_config: %w", err)
+		}
+		if config.FailureAction == swarm.UpdateFailureActionRollback {
+			return fmt.Errorf("rollback_config: failure_action can not be rollback")
+		}
+		targetSpec.RollbackConfig = config
+	}
+
+	return nil
+}
+
+func (r Resources) swarmResources() (*swarm.ResourceRequirements, error) {
+	if r.Limits == nil && r.Reservations == nil {
+		return nil, nil
+	}
+
+	var resources swarm.ResourceRequirements
+
+	if r.Limits != nil {
+		cpus, memory, err := r.Limits.parse()
+		if err != nil {
+			return nil, fmt.Errorf("resources.limits: %w", err)
+		}
+		resources.Limits = &swarm.Limit{
+			NanoCPUs:    cpus,
+			MemoryBytes: memory,
+			Pids:        r.Limits.Pids,
+		}
+	}
+
+	if r.Reservations != nil {
+		if r.Reservations.Pids != 0 {
+			return nil, fmt.Errorf("resources.reservations: pids can only be limited")
+		}
+
+		cpus, memory, err := r.Reservations.parse()
+		if err != nil {
+			return nil, fmt.Errorf("resources.reservations: %w", err)
+		}
+		resources.Reservations = &swarm.Resources{
+			NanoCPUs:    cpus,
+			MemoryBytes: memory,
+		}
+	}
+
+	return &resources, nil
+}
+
+// parse returns the cpus in nano cpus and memory in bytes
+func (r Resource) parse() (int64, int64, error) {
+	var nanoCPUs, memory int64
+
+	if r.Cpus != "" {
+		cpus, err := strconv.ParseFloat(strings.TrimSpace(r.Cpus), 64)
+		if err != nil || cpus < 0 {
+			return 0, 0, fmt.Errorf("invalid cpus %q", r.Cpus)
+		}
+		nanoCPUs = int64(cpus * 1e9)
+	}
+
+	if r.Memory != "" {
+		bytes, err := units.RAMInBytes(strings.TrimSpace(r.Memory))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid memory %q: %w", r.Memory, err)
+		}
+		memory = bytes
+	}
+
+	return nanoCPUs, memory, nil
+}
+
+func (r RestartPolicy) swarmRestartPolicy() (*swarm.RestartPolicy, error) {
+	var policy swarm.RestartPolicy
+
+	switch condition := swarm.RestartPolicyCondition(r.Condition); condition {
+	case "":
+		policy.Condition = swarm.RestartPolicyConditionAny
+	case swarm.RestartPolicyConditionNone, swarm.RestartPolicyConditionOnFailure, swarm.RestartPolicyConditionAny:
+		policy.Condition = condition
+	default:
+		return nil, fmt.Errorf("invalid condition %q, it must be none, on-failure or any", r.Condition)
+	}
+
+	var err error
+	if policy.Delay, err = optionalDuration("delay", r.Delay); err != nil {
+		return nil, err
+	}
+	if policy.Window, err = optionalDuration("window", r.Window); err != nil {
+		return nil, err
+	}
+	policy.MaxAttempts = r.MaxAttempts
+
+	return &policy, nil
+}
+
+func (u UpdateConfig) swarmUpdateConfig() (*swarm.UpdateConfig, error) {
+	config := swarm.UpdateConfig{
+		Parallelism:     1,
+		FailureAction:   swarm.UpdateFailureActionPause,
+		MaxFailureRatio: u.MaxFailureRatio,
+		Order:           swarm.UpdateOrderStopFirst,
+	}
+
+	if u.Parallelism != nil {
+		config.Parallelism = *u.Parallelism
+	}
+
+	switch u.FailureAction {
+	case "":
+	case swarm.UpdateFailureActionContinue, swarm.UpdateFailureActionRollback, swarm.UpdateFailureActionPause:
+		config.FailureAction = u.FailureAction
+	default:
+		return nil, fmt.Errorf("invalid failure_action %q, it must be continue, rollback or pause", u.FailureAction)
+	}
+
+	switch u.Order {
+	case "":
+	case swarm.UpdateOrderStopFirst, swarm.UpdateOrderStartFirst:
+		config.Order = u.Order
+	default:
+		return nil, fmt.Errorf("invalid order %q, it must be stop-first or start-first", u.Order)
+	}
+
+	if u.MaxFailureRatio < 0 || u.MaxFailureRatio > 1 {
+		return nil, fmt.Errorf("invalid max_failure_ratio %v, it must be between 0 and 1", u.MaxFailureRatio)
+	}
+
+	delay, err := optionalDuration("delay", u.Delay)
+	if err != nil {
+		return nil, err
+	}
+	if delay != nil {
+		config.Delay = *delay
+	}
+
+	monitor, err := optionalDuration("monitor", u.Monitor)
+	if err != nil {
+		return nil, err
+	}
+	if monitor != nil {
+		config.Monitor = *monitor
+	}
+
+	return &config, nil
+}
+
+// optionalDuration parses a duration like "10s" or "1m30s", it is nil when not set
+func optionalDuration(field string, value string) (*time.Duration, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil || d < 0 {
+		return nil, fmt.Errorf("invalid %s %q", field, value)
+	}
+
+	return &d, nil
+}
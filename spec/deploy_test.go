@@ -0,0 +1,136 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+	"gopkg.in/yaml.v2"
+)
+
+func TestDeploy(t *testing.T) {
+	data := `
+mode: replicated
+replicas: 3
+resources:
+  limits:
+    cpus: "0.5"
+    memory: 512M
+    pids: 100
+  reservations:
+    cpus: 0.25
+    memory: 128M
+restart_policy:
+  condition: on-failure
+  delay: 5s
+  max_attempts: 3
+  window: 2m
+update_config:
+  parallelism: 2
+  delay: 10s
+  order: start-first
+  failure_action: rollback
+  monitor: 30s
+rollback_config:
+  parallelism: 0
+`
+	var deploy Deploy
+	if err := yaml.Unmarshal([]byte(data), &deploy); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var s swarm.ServiceSpec
+	if err := deploy.applyDeploy(&s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s.Mode.Replicated == nil || *s.Mode.Replicated.Replicas != 3 {
+		t.Error("replicas are not set", s.Mode)
+	}
+
+	limits := s.TaskTemplate.Resources.Limits
+	if limits.NanoCPUs != 5e8 || limits.MemoryBytes != 512*1024*1024 || limits.Pids != 100 {
+		t.Error("invalid limits", limits)
+	}
+
+	reservations := s.TaskTemplate.Resources.Reservations
+	if reservations.NanoCPUs != 2.5e8 || reservations.MemoryBytes != 128*1024*1024 {
+		t.Error("invalid reservations", reservations)
+	}
+
+	restart := s.TaskTemplate.RestartPolicy
+	if restart.Condition != swarm.RestartPolicyConditionOnFailure || *restart.Delay != 5*time.Second ||
+		*restart.MaxAttempts != 3 || *restart.Window != 2*time.Minute {
+		t.Error("invalid restart policy", restart)
+	}
+
+	update := s.UpdateConfig
+	if update.Parallelism != 2 || update.Delay != 10*time.Second || update.Order != swarm.UpdateOrderStartFirst ||
+		update.FailureAction != swarm.UpdateFailureActionRollback || update.Monitor != 30*time.Second {
+		t.Error("invalid update config", update)
+	}
+
+	if s.RollbackConfig.Parallelism != 0 || s.RollbackConfig.FailureAction != swarm.UpdateFailureActionPause {
+		t.Error("invalid rollback config", s.RollbackConfig)
+	}
+}
+
+func TestDeployDefaults(t *testing.T) {
+	var s swarm.ServiceSpec
+	if err := (Deploy{}).applyDeploy(&s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s.Mode.Replicated == nil || *s.Mode.Replicated.Replicas != 1 {
+		t.Error("service should have a single replica by default")
+	}
+
+	if s.TaskTemplate.Resources != nil || s.UpdateConfig != nil || s.TaskTemplate.RestartPolicy != nil {
+		t.Error("docker defaults should be used when not set")
+	}
+}
+
+func TestInvalidDeploy(t *testing.T) {
+	invalid := []string{
+		"mode: daemon",
+		"{mode: global, replicas: 2}",
+		"resources: {limits: {cpus: lots}}",
+		"resources: {limits: {memory: 12XB}}",
+		"resources: {reservations: {pids: 10}}",
+		"restart_policy: {condition: always}",
+		"restart_policy: {delay: 5}",
+		"update_config: {order: random}",
+		"update_config: {failure_action: stop}",
+		"update_config: {max_failure_ratio: 2}",
+		"rollback_config: {failure_action: rollback}",
+	}
+
+	for _, data := range invalid {
+		var deploy Deploy
+		if err := yaml.Unmarshal([]byte(data), &deploy); err != nil {
+			t.Errorf("failed to parse %s: %s", data, err.Error())
+			continue
+		}
+
+		var s swarm.ServiceSpec
+		if err := deploy.applyDeploy(&s); err == nil {
+			t.Errorf("expected error for %s", data)
+		}
+	}
+}
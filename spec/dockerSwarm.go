@@ -17,58 +17,79 @@ limitations under the License.
 package spec
 
 import (
-	"bufio"
-	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
-	"os"
 	"os/user"
 	"path/filepath"
-	"strconv"
+	"sort"
 	"strings"
 
-	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/swarm"
 )
 
 type DockerSwarm struct {
-	Version  string             `yaml:"version"`
-	Services map[string]Service `yaml:"services"`
-	Networks map[string]Network `yaml:"networks"`
-	Volumes  map[string]Volume  `yaml:"volumes"`
+	Version  string                `yaml:"version"`
+	Services map[string]Service    `yaml:"services"`
+	Networks map[string]Network    `yaml:"networks"`
+	Volumes  map[string]Volume     `yaml:"volumes"`
+	Configs  map[string]FileObject `yaml:"configs"`
+	Secrets  map[string]FileObject `yaml:"secrets"`
+
+	Unknown map[string]interface{} `yaml:",inline"`
+
+	// Files are the files of the repository, Dir is the directory of the
+	// service file in it, relative paths in the service file are resolved from Dir
+	Files fs.FS  `yaml:"-"`
+	Dir   string `yaml:"-"`
+
+	// UnsetVariables are the variables used in the service file without a value
+	UnsetVariables []string `yaml:"-"`
 }
 
 type Service struct {
-	Build       string            `yaml:"build"`
-	Image       string            `yaml:"image"`
-	Ports       []string          `yaml:"ports"`
-	Deploy      Deploy            `yaml:"deploy"`
-	Environment map[string]string `yaml:"environment"`
-	EnvFile     []string          `yaml:"env_file"`
-	Volumes     []string          `yaml:"volumes"`
-	Networks    []string          `yaml:"networks"`
+	Build           interface{}         `yaml:"build"` // not supported, only reported in the warnings
+	Image           string              `yaml:"image"`
+	Ports           []Port              `yaml:"ports"`
+	Deploy          Deploy              `yaml:"deploy"`
+	Environment     Mapping             `yaml:"environment"`
+	EnvFile         EnvFiles            `yaml:"env_file"` // relative to the service file in the repository
+	Volumes         []ServiceVolume     `yaml:"volumes"`
+	Networks        ServiceNetworks     `yaml:"networks"`
+	Configs         []ServiceFileObject `yaml:"configs"`
+	Secrets         []ServiceFileObject `yaml:"secrets"`
+	Command         ShellCommand        `yaml:"command"`
+	Entrypoint      ShellCommand        `yaml:"entrypoint"`
+	User            string              `yaml:"user"`
+	WorkingDir      string              `yaml:"working_dir"`
+	Hostname        string              `yaml:"hostname"`
+	GroupAdd        []string            `yaml:"group_add"`
+	Labels          Mapping             `yaml:"labels"` // container labels, service labels are in deploy.labels
+	Healthcheck     *Healthcheck        `yaml:"healthcheck"`
+	StopGracePeriod string              `yaml:"stop_grace_period"`
+	StopSignal      string              `yaml:"stop_signal"`
+	Init            *bool               `yaml:"init"`
+	ReadOnly        bool                `yaml:"read_only"`
+	Tty             bool                `yaml:"tty"`
+	StdinOpen       bool                `yaml:"stdin_open"`
+	CapAdd          []string            `yaml:"cap_add"`
+	CapDrop         []string            `yaml:"cap_drop"`
+	Sysctls         Mapping             `yaml:"sysctls"`
+	Ulimits         map[string]Ulimit   `yaml:"ulimits"`
+	ExtraHosts      ExtraHosts          `yaml:"extra_hosts"`
+	DNS             StringList          `yaml:"dns"`
+	DNSSearch       StringList          `yaml:"dns_search"`
+	DNSOpt          []string            `yaml:"dns_opt"`
+	Logging         *Logging            `yaml:"logging"`
+	Tmpfs           StringList          `yaml:"tmpfs"`
+
+	// Unknown are the fields which are not supported, they are reported in the warnings
+	Unknown map[string]interface{} `yaml:",inline"`
 }
 
-type Deploy struct {
-	Mode     string `yaml:"mode"`
-	Replicas uint64 `yaml:"replicas"`
-}
-
-type Network struct {
-	Name   string            `yaml:"name"`
-	Driver string            `yaml:"driver"`
-	Ipam   map[string]string `yaml:"ipam"`
-}
-
-type Volume struct {
-	Name       string            `yaml:"name"`
-	Driver     string            `yaml:"driver"`
-	DriverOpts map[string]string `yaml:"driver_opts"`
-	Labels     []string          `yaml:"labels"`
-	Options    map[string]string `yaml:"options"`
-}
-
-func (d *DockerSwarm) GetServiceSpec(appName string, networkID string) ([]swarm.ServiceSpec, error) {
+// GetServiceSpec returns the swarm services of the application,
+// refs are the docker networks, configs and secrets used by the services
+func (d *DockerSwarm) GetServiceSpec(appName string, refs ResourceRefs) ([]swarm.ServiceSpec, error) {
 	slog.Info("Getting service spec for app", "app name", appName)
 
 	specs := make([]swarm.ServiceSpec, 0)
@@ -97,91 +118,80 @@ func (d *DockerSwarm) GetServiceSpec(appName string, networkID string) ([]swarm.
 			},
 		}
 
-		// Connection the service with the network
-		targetSpec.TaskTemplate.Networks = append(targetSpec.TaskTemplate.Networks, swarm.NetworkAttachmentConfig{
-			Target: networkID,
-			Aliases: []string{
-				serviceName,
-			},
-		})
+		// Connection the service with the networks
+		networks, err := spec.networkAttachments(serviceName, refs.Networks)
+		if err != nil {
+			return []swarm.ServiceSpec{}, fmt.Errorf("service %s: %w", serviceName, err)
+		}
+		targetSpec.TaskTemplate.Networks = networks
+
+		configs, err := configReferences(spec.Configs, refs.Configs)
+		if err != nil {
+			return []swarm.ServiceSpec{}, fmt.Errorf("service %s: %w", serviceName, err)
+		}
+		targetSpec.TaskTemplate.ContainerSpec.Configs = configs
 
+		secrets, err := secretReferences(spec.Secrets, refs.Secrets)
+		if err != nil {
+			return []swarm.ServiceSpec{}, fmt.Errorf("service %s: %w", serviceName, err)
+		}
+		targetSpec.TaskTemplate.ContainerSpec.Secrets = secrets
+
+		// later env files override the earlier ones and environment overrides them all
+		env := map[string]string{}
 		for _, envFile := range spec.EnvFile {
-			slog.Info("Using environment variable from files", "file", envFile)
+			slog.Info("Using environment variable from files", "file", envFile.Path)
 
-			envVars, err := getEnvVars(envFile)
+			envVars, err := d.getEnvVars(envFile)
 			if err != nil {
-				return []swarm.ServiceSpec{}, err
+				return []swarm.ServiceSpec{}, fmt.Errorf("service %s: %w", serviceName, err)
 			}
 
 			slog.Info("Found environment from file", "count", len(envVars))
 
 			for k, v := range envVars {
-				targetSpec.TaskTemplate.ContainerSpec.Env = append(targetSpec.TaskTemplate.ContainerSpec.Env, k+"="+v)
+				env[k] = v
 			}
 		}
 
 		for k, v := range spec.Environment {
-			targetSpec.TaskTemplate.ContainerSpec.Env = append(targetSpec.TaskTemplate.ContainerSpec.Env, k+"="+v)
+			env[k] = v
 		}
 
-		for _, m := range spec.Volumes {
-			tokens := strings.SplitN(m, ":", 2)
-			if len(tokens) != 2 {
-				slog.Error("Volumes are not split on : in 2", "tokens", tokens)
-				return []swarm.ServiceSpec{}, errors.New("invalid volumes")
-			}
-
-			key := tokens[0]
-			value := tokens[1]
-
-			volumeType := mount.TypeVolume // volume mount
+		for k, v := range env {
+			targetSpec.TaskTemplate.ContainerSpec.Env = append(targetSpec.TaskTemplate.ContainerSpec.Env, k+"="+v)
+		}
 
-			// checking for Bind mounts
-			if strings.HasPrefix(key, ".") ||
-				strings.HasPrefix(key, "~") ||
-				strings.HasPrefix(key, "/") {
-				absPath, err := normalizeFilePath(key)
-				if err != nil {
-					return []swarm.ServiceSpec{}, err
-				}
+		// maps are not ordered, and docker restarts the service if only the order has changed
+		sort.Strings(targetSpec.TaskTemplate.ContainerSpec.Env)
 
-				key = absPath
-				volumeType = mount.TypeBind
+		for _, v := range spec.Volumes {
+			m, err := v.mount(appName, d.Volumes)
+			if err != nil {
+				return []swarm.ServiceSpec{}, fmt.Errorf("service %s: %w", serviceName, err)
 			}
 
-			targetSpec.TaskTemplate.ContainerSpec.Mounts = append(targetSpec.TaskTemplate.ContainerSpec.Mounts, mount.Mount{
-				Type:   volumeType,
-				Source: key,
-				Target: value,
-			})
+			targetSpec.TaskTemplate.ContainerSpec.Mounts = append(targetSpec.TaskTemplate.ContainerSpec.Mounts, m)
 
-			slog.Info("Using volume", "key", key, "value", value)
+			slog.Info("Using volume", "type", m.Type, "source", m.Source, "target", m.Target)
 		}
 
-		if spec.Deploy.Mode == "replicated" {
-			targetSpec.Mode.Replicated = &swarm.ReplicatedService{
-				Replicas: &spec.Deploy.Replicas,
-			}
-		} else if spec.Deploy.Mode == "global" {
-			targetSpec.Mode.Global = &swarm.GlobalService{}
+		if err := spec.applyContainer(&targetSpec); err != nil {
+			return []swarm.ServiceSpec{}, fmt.Errorf("service %s: %w", serviceName, err)
+		}
+
+		if err := spec.Deploy.applyDeploy(&targetSpec); err != nil {
+			return []swarm.ServiceSpec{}, fmt.Errorf("service %s: deploy: %w", serviceName, err)
 		}
 
 		var ports []swarm.PortConfig
 		for _, port := range spec.Ports {
-			tokens := strings.Split(port, ":")
-			if len(tokens) != 2 {
-				slog.Error("ports are not split on : in 2", "tokens", tokens)
-				os.Exit(1)
+			configs, err := port.swarmPorts()
+			if err != nil {
+				return []swarm.ServiceSpec{}, fmt.Errorf("service %s: %w", serviceName, err)
 			}
-			target, _ := strconv.Atoi(tokens[1])
-			publish, _ := strconv.Atoi(tokens[0])
-
-			ports = append(ports, swarm.PortConfig{
-				Protocol:      "tcp",
-				TargetPort:    uint32(target),
-				PublishedPort: uint32(publish),
-				PublishMode:   swarm.PortConfigPublishModeIngress,
-			})
+
+			ports = append(ports, configs...)
 		}
 
 		targetSpec.EndpointSpec = &swarm.EndpointSpec{
@@ -195,53 +205,15 @@ func (d *DockerSwarm) GetServiceSpec(appName string, networkID string) ([]swarm.
 	return specs, nil
 }
 
-func getEnvVars(fileName string) (map[string]string, error) {
-	result := make(map[string]string)
-
-	fileName, err := normalizeFilePath(fileName)
-	if err != nil {
-		return map[string]string{}, err
-	}
-
-	fileData, err := os.Open(fileName)
-	if err != nil {
-		slog.Warn("file path does not exist", "file", fileName)
-		fileName, _ = strings.CutPrefix(fileName, "/home")
-
-		fileData, err = os.Open(fileName)
-		if err != nil {
-			slog.Warn("file path does not exist", "file", fileName)
-			return map[string]string{}, err
-		}
-	}
-	defer fileData.Close()
-
-	scanner := bufio.NewScanner(fileData)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		tokens := strings.SplitN(line, "=", 2)
-
-		if len(tokens) == 2 {
-			key := strings.TrimSpace(tokens[0])
-			value := strings.TrimSpace(tokens[1])
-
-			result[key] = value
-		}
-	}
-
-	return result, err
-}
-
 func normalizeFilePath(fileName string) (string, error) {
 	currentUser, err := user.Current()
 	if err != nil {
 		return "", err
 	}
 
-	username := currentUser.Username
-
-	fileName = strings.ReplaceAll(fileName, "~", fmt.Sprintf("/home/%s", username))
+	if fileName == "~" || strings.HasPrefix(fileName, "~/") {
+		fileName = currentUser.HomeDir + fileName[1:]
+	}
 
 	absFilePath, err := filepath.Abs(fileName)
 	if err != nil {
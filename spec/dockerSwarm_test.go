@@ -17,8 +17,8 @@ limitations under the License.
 package spec
 
 import (
-	"os"
 	"testing"
+	"testing/fstest"
 )
 
 func TestNormalizeFilePath(t *testing.T) {
@@ -57,31 +57,52 @@ func TestNormalizeFilePath(t *testing.T) {
 }
 
 func TestGetEnvVars(t *testing.T) {
-	tempFile, err := os.CreateTemp(os.TempDir(), "test_file-*")
-	if err != nil {
-		t.Error(err.Error())
-	}
-	defer tempFile.Close()
-
-	tempFile.WriteString(`
+	d := DockerSwarm{
+		Dir: "deploy",
+		Files: fstest.MapFS{
+			"deploy/app.env": &fstest.MapFile{Data: []byte(`
 	ENV_1="1"
-	ENV_2="2"
+	ENV_2='2'
 	ENV_3=3
-	ENV_4=4
+	export ENV_4=4
 	# Comment
-	`)
-
-	path := tempFile.Name()
+	`)},
+			"shared/common.env": &fstest.MapFile{Data: []byte("COMMON=yes\n")},
+		},
+	}
 
-	res, err := getEnvVars(path)
+	res, err := d.getEnvVars(EnvFile{Path: "app.env"})
 	if err != nil {
-		t.Error(err.Error())
+		t.Fatal(err.Error())
 	}
 
-	if res["ENV_1"] != "\"1\"" ||
-		res["ENV_2"] != "\"2\"" ||
+	if res["ENV_1"] != "1" ||
+		res["ENV_2"] != "2" ||
 		res["ENV_3"] != "3" ||
-		res["ENV_4"] != "4" {
+		res["ENV_4"] != "4" ||
+		len(res) != 4 {
 		t.Error("failed to convert env file into map[string]string", res)
 	}
+
+	res, err = d.getEnvVars(EnvFile{Path: "../shared/common.env"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if res["COMMON"] != "yes" {
+		t.Error("failed to read env file relative to the service file", res)
+	}
+
+	if _, err := d.getEnvVars(EnvFile{Path: "missing.env"}); err == nil {
+		t.Error("expected error for missing env file")
+	}
+
+	optional := false
+	res, err = d.getEnvVars(EnvFile{Path: "missing.env", Required: &optional})
+	if err != nil || len(res) != 0 {
+		t.Errorf("optional missing env file = %v, %v, want no variables", res, err)
+	}
+
+	if _, err := d.getEnvVars(EnvFile{Path: "/etc/passwd"}); err == nil {
+		t.Error("expected error for env file outside of the repository")
+	}
 }
@@ -0,0 +1,219 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+)
+
+var envKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// EnvFile is an env_file of the service, a path relative to the service file
+// or a map with the path and required (true by default)
+type EnvFile struct {
+	Path     string `yaml:"path"`
+	Required *bool  `yaml:"required"`
+}
+
+type longEnvFile EnvFile
+
+func (e *EnvFile) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var path string
+	if err := unmarshal(&path); err == nil {
+		*e = EnvFile{Path: path}
+		return nil
+	}
+
+	var long longEnvFile
+	if err := unmarshal(&long); err != nil {
+		return fmt.Errorf("invalid env_file: %w", err)
+	}
+
+	if long.Path == "" {
+		return errors.New("invalid env_file: path is required")
+	}
+
+	*e = EnvFile(long)
+	return nil
+}
+
+// EnvFiles is a single env_file or a list of them
+type EnvFiles []EnvFile
+
+func (l *EnvFiles) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var single EnvFile
+	if err := unmarshal(&single); err == nil {
+		*l = EnvFiles{single}
+		return nil
+	}
+
+	var list []EnvFile
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+
+	*l = list
+	return nil
+}
+
+// getEnvVars reads the env file from the repository, relative to the service file
+func (d *DockerSwarm) getEnvVars(envFile EnvFile) (map[string]string, error) {
+	data, err := d.ReadRepoFile(envFile.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if envFile.Required != nil && !*envFile.Required {
+			return map[string]string{}, nil
+		}
+		return nil, fmt.Errorf("env_file %s does not exist in the repository", envFile.Path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("env_file %s: %w", envFile.Path, err)
+	}
+
+	vars, err := parseDotEnv(data)
+	if err != nil {
+		return nil, fmt.Errorf("env_file %s: %w", envFile.Path, err)
+	}
+
+	return vars, nil
+}
+
+// parseDotEnv parses the dotenv syntax: KEY=VALUE lines with optional "export ",
+// comments, unquoted values (trailing " #" comments are removed), single quoted
+// literal values and double quoted values with escapes, quoted values may span lines.
+// A KEY without "=" is skipped, the environment of the meltcd server is never used since
+// it has secrets (like the webhook secret) which must not reach the services
+func parseDotEnv(data []byte) (map[string]string, error) {
+	result := make(map[string]string)
+
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		lineNumber := i + 1
+
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if rest, ok := strings.CutPrefix(line, "export "); ok {
+			line = strings.TrimSpace(rest)
+		}
+
+		key, value, hasValue := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !envKeyRegex.MatchString(key) {
+			return nil, fmt.Errorf("line %d: invalid variable name %q", lineNumber, key)
+		}
+
+		if !hasValue {
+			continue
+		}
+
+		value = strings.TrimLeft(value, " \t")
+
+		if value == "" || (value[0] != '"' && value[0] != '\'') {
+			if index := inlineComment(value); index != -1 {
+				value = value[:index]
+			}
+			result[key] = strings.TrimSpace(value)
+			continue
+		}
+
+		quote := value[0]
+		body := value[1:]
+
+		end := closingQuote(body, quote)
+		for end == -1 {
+			i++
+			if i >= len(lines) {
+				return nil, fmt.Errorf("line %d: missing closing quote of %s", lineNumber, key)
+			}
+			body += "\n" + lines[i]
+			end = closingQuote(body, quote)
+		}
+
+		rest := strings.TrimSpace(body[end+1:])
+		if rest != "" && !strings.HasPrefix(rest, "#") {
+			return nil, fmt.Errorf("line %d: unexpected %q after the quoted value of %s", lineNumber, rest, key)
+		}
+
+		value = body[:end]
+		if quote == '"' {
+			value = unescapeDoubleQuoted(value)
+		}
+		result[key] = value
+	}
+
+	return result, nil
+}
+
+// inlineComment returns the index of a comment (a # after a space) in an unquoted value
+func inlineComment(value string) int {
+	for i := 1; i < len(value); i++ {
+		if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+			return i
+		}
+	}
+	return -1
+}
+
+// closingQuote returns the index of the quote closing the value, a double quote can be escaped
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case quote:
+			return i
+		}
+	}
+	return -1
+}
+
+func unescapeDoubleQuoted(s string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '"', '\\', '$':
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	content := `# comment
+PLAIN=value
+SPACED = spaced value   # comment
+HASH=abc#def
+EMPTY=
+export EXPORTED=1
+SINGLE='literal \n $HOME # not a comment'
+DOUBLE="line\nnext \"quoted\" \$HOME"
+MULTI="first
+second"
+MULTI_SINGLE='a
+b' # comment
+URL=https://example.com/?a=b
+BARE_KEY
+`
+
+	want := map[string]string{
+		"PLAIN":        "value",
+		"SPACED":       "spaced value",
+		"HASH":         "abc#def",
+		"EMPTY":        "",
+		"EXPORTED":     "1",
+		"SINGLE":       `literal \n $HOME # not a comment`,
+		"DOUBLE":       "line\nnext \"quoted\" $HOME",
+		"MULTI":        "first\nsecond",
+		"MULTI_SINGLE": "a\nb",
+		"URL":          "https://example.com/?a=b",
+	}
+
+	got, err := parseDotEnv([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDotEnv() = %q, want %q", got, want)
+	}
+}
+
+func TestDotEnvDoesNotReadServerEnv(t *testing.T) {
+	t.Setenv("MELTCD_WEBHOOK_SECRET", "server secret")
+
+	got, err := parseDotEnv([]byte("MELTCD_WEBHOOK_SECRET\nexport MELTCD_WEBHOOK_SECRET\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value, ok := got["MELTCD_WEBHOOK_SECRET"]; ok {
+		t.Errorf("environment of the server is used for a bare key: %q", value)
+	}
+}
+
+func TestInvalidDotEnv(t *testing.T) {
+	invalid := []string{
+		"1KEY=value",
+		"KEY WITH SPACE=value",
+		`KEY="not closed`,
+		`KEY="value" trailing`,
+		"=value",
+	}
+
+	for _, s := range invalid {
+		if _, err := parseDotEnv([]byte(s)); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestEnvFiles(t *testing.T) {
+	var service Service
+	err := yaml.Unmarshal([]byte(`
+env_file:
+  - app.env
+  - path: local.env
+    required: false
+environment:
+  - DEBUG=1
+  - EMPTY
+`), &service)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(service.EnvFile) != 2 ||
+		service.EnvFile[0].Path != "app.env" ||
+		service.EnvFile[1].Path != "local.env" ||
+		service.EnvFile[1].Required == nil || *service.EnvFile[1].Required {
+		t.Errorf("env_file = %+v", service.EnvFile)
+	}
+
+	if service.Environment["DEBUG"] != "1" || len(service.Environment) != 2 {
+		t.Errorf("environment = %v", service.Environment)
+	}
+
+	var single Service
+	if err := yaml.Unmarshal([]byte(`env_file: .env`), &single); err != nil {
+		t.Fatal(err)
+	}
+	if len(single.EnvFile) != 1 || single.EnvFile[0].Path != ".env" {
+		t.Errorf("env_file = %+v", single.EnvFile)
+	}
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+var variableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+
+// ValidVariableName tells if name can be used as a variable in the service file
+func ValidVariableName(name string) bool {
+	return variableNameRegex.FindString(name) == name && name != ""
+}
+
+// resolver substitutes the variables of the service file, the variables used without a value
+// and without a default (like $VAR or ${VAR}) are recorded in unset
+type resolver struct {
+	lookup func(string) (string, bool)
+	unset  map[string]bool
+}
+
+func newResolver(lookup func(string) (string, bool)) *resolver {
+	return &resolver{lookup: lookup, unset: map[string]bool{}}
+}
+
+// value returns the value of the variable, an empty string when it is not set
+func (r *resolver) value(name string) string {
+	value, ok := r.lookup(name)
+	if !ok {
+		r.unset[name] = true
+	}
+	return value
+}
+
+// interpolateNode substitutes the variables in the scalar values of the yaml tree,
+// keys are not substituted. A plain scalar is resolved again after the substitution,
+// so "replicas: ${REPLICAS}" is a number, a quoted one stays a string
+func (r *resolver) interpolateNode(node *yaml3.Node) error {
+	switch node.Kind {
+	case yaml3.DocumentNode, yaml3.SequenceNode:
+		for _, child := range node.Content {
+			if err := r.interpolateNode(child); err != nil {
+				return err
+			}
+		}
+	case yaml3.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := r.interpolateNode(node.Content[i]); err != nil {
+				return err
+			}
+		}
+	case yaml3.ScalarNode:
+		if !strings.Contains(node.Value, "$") {
+			return nil
+		}
+
+		value, err := r.interpolate(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+
+		node.Value = value
+		if node.Style == 0 {
+			node.Tag = ""
+		}
+	}
+
+	return nil
+}
+
+// interpolate substitutes the variables in s like the compose specification:
+// $VAR, ${VAR}, ${VAR:-default} (unset or empty), ${VAR-default} (unset),
+// ${VAR:?error} and ${VAR?error} (required), ${VAR:+alternative} and ${VAR+alternative},
+// and $$ for a literal $. Defaults and alternatives can contain variables
+func (r *resolver) interpolate(s string) (string, error) {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		if i+1 < len(s) && s[i+1] == '$' {
+			b.WriteByte('$')
+			i++
+			continue
+		}
+
+		if i+1 < len(s) && s[i+1] == '{' {
+			end := closingBrace(s, i+2)
+			if end == -1 {
+				return "", fmt.Errorf("invalid interpolation %q, missing }", s[i:])
+			}
+
+			value, err := r.substitute(s[i+2 : end])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+
+			i = end
+			continue
+		}
+
+		name := variableNameRegex.FindString(s[i+1:])
+		if name == "" {
+			return "", fmt.Errorf("invalid interpolation %q, use $$ for a literal $", s)
+		}
+
+		b.WriteString(r.value(name))
+
+		i += len(name)
+	}
+
+	return b.String(), nil
+}
+
+// closingBrace returns the index of the } closing the ${ before start
+func closingBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '$':
+			i++
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// substitute returns the value of the expression inside ${}
+func (r *resolver) substitute(expr string) (string, error) {
+	name := variableNameRegex.FindString(expr)
+	if name == "" {
+		return "", fmt.Errorf("invalid interpolation ${%s}, missing variable name", expr)
+	}
+
+	rest := expr[len(name):]
+	if rest == "" {
+		return r.value(name), nil
+	}
+
+	value, set := r.lookup(name)
+
+	operator := rest[:1]
+	checkEmpty := false
+	if operator == ":" && len(rest) > 1 {
+		operator = rest[1:2]
+		checkEmpty = true
+		rest = rest[1:]
+	}
+	argument := rest[1:]
+
+	// with ":" an empty value is the same as an unset one
+	present := set && (!checkEmpty || value != "")
+
+	switch operator {
+	case "-":
+		if present {
+			return value, nil
+		}
+		return r.interpolate(argument)
+	case "+":
+		if !present {
+			return "", nil
+		}
+		return r.interpolate(argument)
+	case "?":
+		if present {
+			return value, nil
+		}
+		message, err := r.interpolate(argument)
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("required variable %s is missing a value: %s", name, message)
+	default:
+		return "", fmt.Errorf("invalid interpolation ${%s}", expr)
+	}
+}
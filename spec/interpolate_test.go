@@ -0,0 +1,171 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestInterpolate(t *testing.T) {
+	variables := map[string]string{
+		"TAG":   "1.4.2",
+		"EMPTY": "",
+		"HOST":  "example.com",
+	}
+	r := newResolver(func(name string) (string, bool) {
+		value, ok := variables[name]
+		return value, ok
+	})
+
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"app:${TAG}", "app:1.4.2"},
+		{"app:$TAG", "app:1.4.2"},
+		{"$HOST/path", "example.com/path"},
+		{"${UNSET}", ""},
+		{"${UNSET:-latest}", "latest"},
+		{"${EMPTY:-latest}", "latest"},
+		{"${EMPTY-latest}", ""},
+		{"${UNSET-latest}", "latest"},
+		{"${TAG:+set}", "set"},
+		{"${EMPTY:+set}", ""},
+		{"${EMPTY+set}", "set"},
+		{"${UNSET+set}", ""},
+		{"${TAG:?tag is required}", "1.4.2"},
+		{"${UNSET:-${TAG}}", "1.4.2"},
+		{"${UNSET:-${ALSO_UNSET:-default}}", "default"},
+		{"price $$5", "price $5"},
+		{"$${TAG}", "${TAG}"},
+		{"no variables", "no variables"},
+	}
+
+	for _, c := range cases {
+		got, err := r.interpolate(c.in)
+		if err != nil {
+			t.Errorf("interpolate(%q) failed: %s", c.in, err.Error())
+			continue
+		}
+		if got != c.want {
+			t.Errorf("interpolate(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+
+	invalid := []string{
+		"${UNSET:?tag is required}",
+		"${EMPTY:?tag is required}",
+		"${UNSET?tag is required}",
+		"${TAG",
+		"${}",
+		"${TAG:}",
+		"${TAG!x}",
+		"$5",
+		"end$",
+	}
+
+	for _, s := range invalid {
+		if _, err := r.interpolate(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	files := fstest.MapFS{
+		"deploy/.env": &fstest.MapFile{Data: []byte("IMAGE_TAG=from-dotenv\nREPLICAS=2\nDEBUG=true\n")},
+	}
+
+	data := []byte(`
+services:
+  web:
+    image: "nginx:${IMAGE_TAG:-latest}"
+    deploy:
+      replicas: ${REPLICAS}
+    environment:
+      DEBUG: ${DEBUG}
+      VERSION: "${VERSION}"
+      PRICE: $$5
+      ${KEY}: key is not interpolated
+`)
+
+	d, err := Load(data, files, "deploy", map[string]string{
+		"IMAGE_TAG": "1.4.2",
+		"VERSION":   "1.10",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := d.Services["web"]
+	if web.Image != "nginx:1.4.2" {
+		t.Errorf("image = %q, parameters must take precedence over .env", web.Image)
+	}
+	if web.Deploy.Replicas == nil || *web.Deploy.Replicas != 2 {
+		t.Errorf("replicas = %v, want 2", web.Deploy.Replicas)
+	}
+
+	wantEnv := Mapping{
+		"DEBUG":   "true",
+		"VERSION": "1.10",
+		"PRICE":   "$5",
+		"${KEY}":  "key is not interpolated",
+	}
+	if !reflect.DeepEqual(web.Environment, wantEnv) {
+		t.Errorf("environment = %v, want %v", web.Environment, wantEnv)
+	}
+
+	if d.Dir != "deploy" || d.Files == nil {
+		t.Error("files of the repository are not kept")
+	}
+
+	if _, err := Load([]byte("services:\n  web:\n    image: ${IMAGE:?image is required}\n"), nil, ".", nil); err == nil {
+		t.Error("expected error for required variable")
+	}
+}
+
+func TestUnsetVariables(t *testing.T) {
+	d, err := Load([]byte("services:\n  web:\n    image: nginx:${TAG}\n    hostname: ${HOST}\n"), nil, ".", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(d.UnsetVariables, []string{"HOST", "TAG"}) {
+		t.Errorf("unset variables = %v", d.UnsetVariables)
+	}
+
+	warnings := d.Warnings()
+	if len(warnings) != 2 || warnings[0] != "variable HOST is not set, an empty string is used" {
+		t.Errorf("warnings = %v", warnings)
+	}
+
+	// a default (or an alternative) is used on purpose, so the variable is not reported
+	data := "services:\n  web:\n    image: nginx:${TAG:-latest}\n    hostname: ${HOST-web}\n    user: ${USER_ID:+1000}\n    working_dir: /${DIR}\n"
+	d, err = Load([]byte(data), nil, ".", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Services["web"].Image != "nginx:latest" || d.Services["web"].Hostname != "web" {
+		t.Errorf("service = %+v", d.Services["web"])
+	}
+	if !reflect.DeepEqual(d.UnsetVariables, []string{"DIR"}) {
+		t.Errorf("unset variables = %v, want only DIR", d.UnsetVariables)
+	}
+}
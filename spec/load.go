@@ -0,0 +1,112 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// Load parses the service file, files are the files of the repository and dir is the
+// directory of the service file in it. Variables in the values (${VAR}) are substituted
+// with the parameters, then with the .env file next to the service file
+func Load(data []byte, files fs.FS, dir string, parameters map[string]string) (DockerSwarm, error) {
+	d := DockerSwarm{Files: files, Dir: dir}
+
+	if bytes.Contains(data, []byte("$")) {
+		variables, err := d.variables(parameters)
+		if err != nil {
+			return DockerSwarm{}, err
+		}
+
+		r := newResolver(func(name string) (string, bool) {
+			value, ok := variables[name]
+			return value, ok
+		})
+
+		data, err = r.interpolateYAML(data)
+		if err != nil {
+			return DockerSwarm{}, err
+		}
+
+		for name := range r.unset {
+			d.UnsetVariables = append(d.UnsetVariables, name)
+		}
+		sort.Strings(d.UnsetVariables)
+	}
+
+	if err := yaml.Unmarshal(data, &d); err != nil {
+		return DockerSwarm{}, err
+	}
+
+	d.Files = files
+	d.Dir = dir
+
+	return d, nil
+}
+
+// variables are the parameters of the application and the .env file next to the service file,
+// the parameters take precedence
+func (d *DockerSwarm) variables(parameters map[string]string) (map[string]string, error) {
+	variables := map[string]string{}
+
+	if d.Files != nil {
+		data, err := d.ReadRepoFile(".env")
+		switch {
+		case err == nil:
+			dotEnv, err := parseDotEnv(data)
+			if err != nil {
+				return nil, fmt.Errorf(".env: %w", err)
+			}
+			for k, v := range dotEnv {
+				variables[k] = v
+			}
+		case errors.Is(err, fs.ErrNotExist):
+		default:
+			return nil, fmt.Errorf(".env: %w", err)
+		}
+	}
+
+	for k, v := range parameters {
+		variables[k] = v
+	}
+
+	return variables, nil
+}
+
+func (r *resolver) interpolateYAML(data []byte) ([]byte, error) {
+	var root yaml3.Node
+	if err := yaml3.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	if root.Kind == 0 {
+		return data, nil
+	}
+
+	if err := r.interpolateNode(&root); err != nil {
+		return nil, err
+	}
+
+	return yaml3.Marshal(&root)
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mapping is a compose mapping (like labels) written as a map
+// or as a list of "KEY=VALUE", a value can be empty
+type Mapping map[string]string
+
+func (m *Mapping) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var values map[string]interface{}
+	if err := unmarshal(&values); err == nil {
+		result := make(Mapping, len(values))
+		for k, v := range values {
+			if v == nil {
+				result[k] = ""
+				continue
+			}
+			result[k] = fmt.Sprint(v)
+		}
+
+		*m = result
+		return nil
+	}
+
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return fmt.Errorf("expected a map or a list of KEY=VALUE")
+	}
+
+	result := make(Mapping, len(list))
+	for _, item := range list {
+		k, v, _ := strings.Cut(item, "=")
+		if strings.TrimSpace(k) == "" {
+			return fmt.Errorf("invalid item %q, expected KEY=VALUE", item)
+		}
+		result[strings.TrimSpace(k)] = v
+	}
+
+	*m = result
+	return nil
+}
@@ -0,0 +1,415 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"fmt"
+	"strings"
+
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// mergeRule is how a sequence (or a mapping written as a list) of the
+// service is merged with the one of the overriding file
+type mergeRule int
+
+const (
+	appendRule     mergeRule = iota // values of the override are appended
+	replaceRule                     // the override replaces the value (like command)
+	mappingRule                     // list of KEY=VALUE or map, merged by key (like environment)
+	uniqueRule                      // values are appended without duplicates (like dns)
+	volumeRule                      // merged by the target path
+	fileObjectRule                  // configs and secrets, merged by the target (or source)
+	portRule                        // merged by the host ip, published and target port and protocol
+	networkRule                     // list or map of networks, merged by name
+)
+
+// serviceMergeRules are the merge rules of the service fields which are not merged
+// by the default rules (mappings are merged by key, sequences are appended and scalars replaced)
+var serviceMergeRules = map[string]mergeRule{
+	"command":          replaceRule,
+	"entrypoint":       replaceRule,
+	"healthcheck.test": replaceRule,
+	"environment":      mappingRule,
+	"labels":           mappingRule,
+	"deploy.labels":    mappingRule,
+	"sysctls":          mappingRule,
+	"dns":              uniqueRule,
+	"dns_search":       uniqueRule,
+	"dns_opt":          uniqueRule,
+	"env_file":         uniqueRule,
+	"tmpfs":            uniqueRule,
+	"cap_add":          uniqueRule,
+	"cap_drop":         uniqueRule,
+	"group_add":        uniqueRule,
+	"extra_hosts":      uniqueRule,
+	"volumes":          volumeRule,
+	"configs":          fileObjectRule,
+	"secrets":          fileObjectRule,
+	"ports":            portRule,
+	"networks":         networkRule,
+}
+
+// Merge merges the compose files in order with the compose override rules, a later
+// file overrides the earlier ones: mappings (like services) are merged by key, scalars
+// are replaced, sequences are appended, except the fields with their own rules like
+// environment and labels (merged by key), volumes (by target) and ports.
+// A value tagged !reset is removed and a value tagged !override replaces the merged one
+func Merge(files ...[]byte) ([]byte, error) {
+	var merged *yaml3.Node
+
+	for i, data := range files {
+		var doc yaml3.Node
+		if err := yaml3.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("file %d: %w", i+1, err)
+		}
+
+		if doc.Kind == 0 || len(doc.Content) == 0 {
+			continue
+		}
+
+		root := doc.Content[0]
+		if root.Kind != yaml3.MappingNode {
+			return nil, fmt.Errorf("file %d: expected a mapping at the top level", i+1)
+		}
+		expandAliases(root)
+
+		if merged == nil {
+			merged = root
+			removeResets(merged)
+			continue
+		}
+
+		merged = mergeNodes("", merged, root)
+	}
+
+	if merged == nil {
+		return []byte{}, nil
+	}
+
+	return yaml3.Marshal(merged)
+}
+
+func mergeNodes(path string, base *yaml3.Node, override *yaml3.Node) *yaml3.Node {
+	if override.Tag == "!override" {
+		override.Tag = ""
+		removeResets(override)
+		return override
+	}
+
+	if rule, ok := serviceRule(path); ok {
+		return mergeWithRule(rule, base, override)
+	}
+
+	if base.Kind != yaml3.MappingNode || override.Kind != yaml3.MappingNode {
+		if base.Kind == yaml3.SequenceNode && override.Kind == yaml3.SequenceNode {
+			base.Content = append(base.Content, override.Content...)
+			removeResets(base)
+			return base
+		}
+
+		removeResets(override)
+		return override
+	}
+
+	for i := 0; i+1 < len(override.Content); i += 2 {
+		key, value := override.Content[i], override.Content[i+1]
+		index := mappingIndex(base, key.Value)
+
+		switch {
+		case value.Tag == "!reset":
+			if index != -1 {
+				base.Content = append(base.Content[:index], base.Content[index+2:]...)
+			}
+		case index == -1:
+			removeResets(value)
+			base.Content = append(base.Content, key, value)
+		default:
+			base.Content[index+1] = mergeNodes(joinPath(path, key.Value), base.Content[index+1], value)
+		}
+	}
+
+	return base
+}
+
+// serviceRule returns the merge rule of the field of a service at the path like "services.web.environment"
+func serviceRule(path string) (mergeRule, bool) {
+	parts := strings.SplitN(path, ".", 3)
+	if len(parts) != 3 || parts[0] != "services" {
+		return 0, false
+	}
+
+	rule, ok := serviceMergeRules[parts[2]]
+	return rule, ok
+}
+
+func mergeWithRule(rule mergeRule, base *yaml3.Node, override *yaml3.Node) *yaml3.Node {
+	removeResets(override)
+
+	switch rule {
+	case replaceRule:
+		return override
+	case mappingRule:
+		return mergeMappings(toMapping(base, "="), toMapping(override, "="))
+	case networkRule:
+		if base.Kind == yaml3.SequenceNode && override.Kind == yaml3.SequenceNode {
+			return mergeUnique(base, override, scalarKey)
+		}
+		return mergeMappings(toMapping(base, ""), toMapping(override, ""))
+	case uniqueRule:
+		return mergeUnique(toSequence(base), toSequence(override), scalarKey)
+	case volumeRule:
+		return mergeUnique(toSequence(base), toSequence(override), volumeKey)
+	case fileObjectRule:
+		return mergeUnique(toSequence(base), toSequence(override), fileObjectKey)
+	case portRule:
+		return mergeUnique(toSequence(base), toSequence(override), portKey)
+	}
+
+	return override
+}
+
+// mergeMappings merges the mappings by key, a value of the override replaces the base one
+func mergeMappings(base *yaml3.Node, override *yaml3.Node) *yaml3.Node {
+	if base.Kind != yaml3.MappingNode || override.Kind != yaml3.MappingNode {
+		return override
+	}
+
+	for i := 0; i+1 < len(override.Content); i += 2 {
+		key, value := override.Content[i], override.Content[i+1]
+		if index := mappingIndex(base, key.Value); index != -1 {
+			base.Content[index+1] = value
+			continue
+		}
+		base.Content = append(base.Content, key, value)
+	}
+
+	return base
+}
+
+// mergeUnique appends the items of the override, an item replaces the base item with the same key
+func mergeUnique(base *yaml3.Node, override *yaml3.Node, key func(*yaml3.Node) string) *yaml3.Node {
+	if base.Kind != yaml3.SequenceNode || override.Kind != yaml3.SequenceNode {
+		return override
+	}
+
+	for _, item := range override.Content {
+		k := key(item)
+
+		replaced := false
+		for i, existing := range base.Content {
+			if key(existing) == k {
+				base.Content[i] = item
+				replaced = true
+				break
+			}
+		}
+
+		if !replaced {
+			base.Content = append(base.Content, item)
+		}
+	}
+
+	return base
+}
+
+// toMapping converts a list of KEY<separator>VALUE (or only KEY) to a mapping
+func toMapping(node *yaml3.Node, separator string) *yaml3.Node {
+	if node.Kind != yaml3.SequenceNode {
+		return node
+	}
+
+	mapping := &yaml3.Node{Kind: yaml3.MappingNode, Tag: "!!map"}
+	for _, item := range node.Content {
+		key, value, hasValue := item.Value, "", false
+		if separator != "" {
+			key, value, hasValue = strings.Cut(item.Value, separator)
+		}
+
+		valueNode := &yaml3.Node{Kind: yaml3.ScalarNode, Tag: "!!null", Value: "null"}
+		if hasValue {
+			valueNode = &yaml3.Node{Kind: yaml3.ScalarNode, Tag: "!!str", Value: value, Style: yaml3.DoubleQuotedStyle}
+		}
+
+		mapping.Content = append(mapping.Content, &yaml3.Node{Kind: yaml3.ScalarNode, Tag: "!!str", Value: key}, valueNode)
+	}
+
+	return mapping
+}
+
+// toSequence converts a single value (like dns: 8.8.8.8) to a sequence
+func toSequence(node *yaml3.Node) *yaml3.Node {
+	if node.Kind == yaml3.ScalarNode {
+		return &yaml3.Node{Kind: yaml3.SequenceNode, Tag: "!!seq", Content: []*yaml3.Node{node}}
+	}
+	return node
+}
+
+func scalarKey(node *yaml3.Node) string {
+	if node.Kind != yaml3.ScalarNode {
+		out, _ := yaml3.Marshal(node)
+		return string(out)
+	}
+	return node.Value
+}
+
+// volumeKey is the target path of the volume in the short ([SOURCE:]TARGET[:MODE]) or long syntax
+func volumeKey(node *yaml3.Node) string {
+	if node.Kind == yaml3.MappingNode {
+		return mappingValue(node, "target")
+	}
+
+	parts := strings.Split(node.Value, ":")
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return parts[1]
+}
+
+func fileObjectKey(node *yaml3.Node) string {
+	if node.Kind == yaml3.MappingNode {
+		if target := mappingValue(node, "target"); target != "" {
+			return target
+		}
+		return "/" + mappingValue(node, "source")
+	}
+	return "/" + node.Value
+}
+
+func portKey(node *yaml3.Node) string {
+	if node.Kind == yaml3.MappingNode {
+		protocol := mappingValue(node, "protocol")
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		return fmt.Sprintf("%s:%s:%s/%s", mappingValue(node, "host_ip"), mappingValue(node, "published"), mappingValue(node, "target"), protocol)
+	}
+
+	port, err := parsePort(node.Value)
+	if err != nil {
+		return node.Value
+	}
+	if port.Protocol == "" {
+		port.Protocol = "tcp"
+	}
+	return fmt.Sprintf("%s:%s:%s/%s", port.HostIP, port.Published, port.Target, port.Protocol)
+}
+
+func mappingIndex(node *yaml3.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func mappingValue(node *yaml3.Node, key string) string {
+	if index := mappingIndex(node, key); index != -1 {
+		return node.Content[index+1].Value
+	}
+	return ""
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// removeResets removes the values tagged !reset (they only reset the values of the earlier files)
+// and the !override tags
+func removeResets(node *yaml3.Node) {
+	if node.Tag == "!override" {
+		node.Tag = ""
+	}
+
+	switch node.Kind {
+	case yaml3.MappingNode:
+		content := node.Content[:0]
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i+1].Tag == "!reset" {
+				continue
+			}
+			removeResets(node.Content[i+1])
+			content = append(content, node.Content[i], node.Content[i+1])
+		}
+		node.Content = content
+	case yaml3.SequenceNode:
+		for _, item := range node.Content {
+			removeResets(item)
+		}
+	}
+}
+
+// expandAliases replaces the aliases with a copy of the anchored values and expands
+// the merge keys (<<), so the merged nodes do not depend on anchors of the other files
+func expandAliases(node *yaml3.Node) {
+	if node.Kind == yaml3.AliasNode && node.Alias != nil {
+		*node = *copyNode(node.Alias)
+	}
+	node.Anchor = ""
+
+	for _, child := range node.Content {
+		expandAliases(child)
+	}
+
+	if node.Kind != yaml3.MappingNode {
+		return
+	}
+
+	var content, inherited []*yaml3.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Tag != "!!merge" {
+			content = append(content, key, value)
+			continue
+		}
+
+		sources := []*yaml3.Node{value}
+		if value.Kind == yaml3.SequenceNode {
+			sources = value.Content
+		}
+		for _, source := range sources {
+			inherited = append(inherited, source.Content...)
+		}
+	}
+
+	// keys of the mapping take precedence over the merged ones, then the first merged source
+	result := &yaml3.Node{Kind: yaml3.MappingNode, Content: content}
+	for i := 0; i+1 < len(inherited); i += 2 {
+		if mappingIndex(result, inherited[i].Value) == -1 {
+			result.Content = append(result.Content, inherited[i], inherited[i+1])
+		}
+	}
+	node.Content = result.Content
+}
+
+func copyNode(node *yaml3.Node) *yaml3.Node {
+	if node.Kind == yaml3.AliasNode && node.Alias != nil {
+		return copyNode(node.Alias)
+	}
+
+	c := *node
+	c.Anchor = ""
+	c.Content = make([]*yaml3.Node, len(node.Content))
+	for i, child := range node.Content {
+		c.Content[i] = copyNode(child)
+	}
+	return &c
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestMerge(t *testing.T) {
+	base := []byte(`
+x-defaults: &defaults
+  restart: always
+  labels:
+    team: web
+
+services:
+  web:
+    <<: *defaults
+    image: nginx:1.25
+    command: ["nginx", "-g", "daemon off;"]
+    environment:
+      - LOG_LEVEL=info
+      - WORKERS=2
+    ports:
+      - "80:80"
+    volumes:
+      - data:/var/lib/data
+      - ./config:/etc/nginx:ro
+    dns: 8.8.8.8
+    deploy:
+      replicas: 1
+      placement:
+        constraints:
+          - node.role == worker
+    networks:
+      - front
+  worker:
+    image: worker:1.0
+
+networks:
+  front:
+    driver: overlay
+`)
+
+	override := []byte(`
+services:
+  web:
+    image: nginx:1.26
+    command: nginx-debug
+    environment:
+      LOG_LEVEL: debug
+      EXTRA: "1"
+    labels:
+      - tier=frontend
+    ports:
+      - "80:80"
+      - "443:443"
+    volumes:
+      - /srv/nginx:/etc/nginx:ro
+    dns:
+      - 8.8.8.8
+      - 1.1.1.1
+    deploy:
+      replicas: 3
+      placement:
+        constraints:
+          - node.labels.zone == eu
+    networks:
+      back:
+        aliases: [api]
+  worker: !reset
+  cron:
+    image: cron:1.0
+
+networks:
+  front:
+    attachable: true
+  back: {}
+`)
+
+	merged, err := Merge(base, override)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var d DockerSwarm
+	if err := yaml.Unmarshal(merged, &d); err != nil {
+		t.Fatalf("merged file is not valid: %s\n%s", err.Error(), merged)
+	}
+
+	if _, ok := d.Services["worker"]; ok {
+		t.Error("worker is reset, it must be removed")
+	}
+	if _, ok := d.Services["cron"]; !ok {
+		t.Error("cron service is not added")
+	}
+
+	web := d.Services["web"]
+
+	if web.Image != "nginx:1.26" {
+		t.Errorf("image = %q", web.Image)
+	}
+	if !reflect.DeepEqual([]string(web.Command), []string{"nginx-debug"}) {
+		t.Errorf("command = %v, it must be replaced", web.Command)
+	}
+	if !reflect.DeepEqual(web.Environment, Mapping{"LOG_LEVEL": "debug", "WORKERS": "2", "EXTRA": "1"}) {
+		t.Errorf("environment = %v", web.Environment)
+	}
+	if !reflect.DeepEqual(web.Labels, Mapping{"team": "web", "tier": "frontend"}) {
+		t.Errorf("labels = %v", web.Labels)
+	}
+	if len(web.Ports) != 2 {
+		t.Errorf("ports = %v, want 80 and 443", web.Ports)
+	}
+	if len(web.Volumes) != 2 || web.Volumes[1].Source != "/srv/nginx" {
+		t.Errorf("volumes = %+v, /etc/nginx must be replaced", web.Volumes)
+	}
+	if !reflect.DeepEqual([]string(web.DNS), []string{"8.8.8.8", "1.1.1.1"}) {
+		t.Errorf("dns = %v", web.DNS)
+	}
+	if web.Deploy.Replicas == nil || *web.Deploy.Replicas != 3 {
+		t.Errorf("replicas = %v", web.Deploy.Replicas)
+	}
+	if len(web.Deploy.Placement.Constraints) != 2 {
+		t.Errorf("constraints = %v, they must be appended", web.Deploy.Placement.Constraints)
+	}
+	if _, ok := web.Networks["front"]; !ok {
+		t.Errorf("networks = %v, front must be kept", web.Networks)
+	}
+	if len(web.Networks["back"].Aliases) != 1 {
+		t.Errorf("networks = %v, back must be added", web.Networks)
+	}
+
+	if d.Networks["front"].Driver != "overlay" || !d.Networks["front"].Attachable {
+		t.Errorf("network front = %+v, it must be merged", d.Networks["front"])
+	}
+}
+
+func TestMergeOverride(t *testing.T) {
+	merged, err := Merge(
+		[]byte("services:\n  web:\n    image: nginx\n    environment:\n      A: \"1\"\n      B: \"2\"\n"),
+		[]byte("services:\n  web:\n    environment: !override\n      C: \"3\"\n"),
+		[]byte(""),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var d DockerSwarm
+	if err := yaml.Unmarshal(merged, &d); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(d.Services["web"].Environment, Mapping{"C": "3"}) {
+		t.Errorf("environment = %v, it must be replaced", d.Services["web"].Environment)
+	}
+}
+
+func TestMergeKeepsVariables(t *testing.T) {
+	merged, err := Merge(
+		[]byte("services:\n  web:\n    image: nginx\n"),
+		[]byte("services:\n  web:\n    deploy:\n      replicas: ${REPLICAS}\n"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := Load(merged, nil, ".", map[string]string{"REPLICAS": "4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r := d.Services["web"].Deploy.Replicas; r == nil || *r != 4 {
+		t.Errorf("replicas = %v, want 4", r)
+	}
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// DefaultNetwork is the network of the services which do not list any network
+const DefaultNetwork = "default"
+
+type Network struct {
+	Name       string            `yaml:"name"`
+	Driver     string            `yaml:"driver"` // overlay by default
+	DriverOpts map[string]string `yaml:"driver_opts"`
+	Ipam       Ipam              `yaml:"ipam"`
+	Attachable bool              `yaml:"attachable"`
+	Internal   bool              `yaml:"internal"`
+	EnableIPv6 bool              `yaml:"enable_ipv6"`
+	Labels     Mapping           `yaml:"labels"`
+	External   External          `yaml:"external"`
+}
+
+type Ipam struct {
+	Driver  string            `yaml:"driver"`
+	Config  []IpamConfig      `yaml:"config"`
+	Options map[string]string `yaml:"options"`
+}
+
+type IpamConfig struct {
+	Subnet       string            `yaml:"subnet"`
+	IPRange      string            `yaml:"ip_range"`
+	Gateway      string            `yaml:"gateway"`
+	AuxAddresses map[string]string `yaml:"aux_addresses"`
+}
+
+// External is "external: true" or the legacy "external: {name: ...}",
+// external networks are created outside of the application and are never removed by meltcd
+type External struct {
+	External bool
+	Name     string
+}
+
+func (e *External) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var external bool
+	if err := unmarshal(&external); err == nil {
+		*e = External{External: external}
+		return nil
+	}
+
+	var legacy struct {
+		Name string `yaml:"name"`
+	}
+	if err := unmarshal(&legacy); err != nil {
+		return fmt.Errorf("external must be true, false or {name: <name>}")
+	}
+
+	*e = External{External: true, Name: legacy.Name}
+	return nil
+}
+
+// ServiceNetworks are the networks of a service, written as a list of names
+// or as a map of name to the attachment options
+type ServiceNetworks map[string]ServiceNetwork
+
+type ServiceNetwork struct {
+	Aliases    []string          `yaml:"aliases"`
+	DriverOpts map[string]string `yaml:"driver_opts"`
+}
+
+func (n *ServiceNetworks) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var names []string
+	if err := unmarshal(&names); err == nil {
+		result := make(ServiceNetworks, len(names))
+		for _, name := range names {
+			result[name] = ServiceNetwork{}
+		}
+
+		*n = result
+		return nil
+	}
+
+	// a network without options is written as "name:" (null)
+	var networks map[string]*ServiceNetwork
+	if err := unmarshal(&networks); err != nil {
+		return fmt.Errorf("networks must be a list of names or a map of name to options")
+	}
+
+	result := make(ServiceNetworks, len(networks))
+	for name, options := range networks {
+		if options == nil {
+			options = &ServiceNetwork{}
+		}
+		result[name] = *options
+	}
+
+	*n = result
+	return nil
+}
+
+// serviceNetworks returns the networks of the service, the default network if none is listed
+func (s Service) serviceNetworks() ServiceNetworks {
+	if len(s.Networks) == 0 {
+		return ServiceNetworks{DefaultNetwork: {}}
+	}
+	return s.Networks
+}
+
+// UsedNetworks returns the networks the services are attached to, keyed by the name in the
+// service file, the default network has the default options if it is not defined
+func (d *DockerSwarm) UsedNetworks() (map[string]Network, error) {
+	used := map[string]Network{}
+
+	for serviceName, service := range d.Services {
+		for name := range service.serviceNetworks() {
+			n, defined := d.Networks[name]
+			if !defined && name != DefaultNetwork {
+				return nil, fmt.Errorf("service %s uses network %s which is not defined in networks", serviceName, name)
+			}
+
+			used[name] = n
+		}
+	}
+
+	return used, nil
+}
+
+// DockerName is the name of the network in docker, like "<app>_<name>"
+// unless the name is set in the service file
+func (n Network) DockerName(appName string, name string) string {
+	switch {
+	case n.External.Name != "":
+		return n.External.Name
+	case n.Name != "":
+		return n.Name
+	case n.External.External:
+		return name
+	}
+
+	return appName + "_" + name
+}
+
+// CreateOptions are the options to create the network, labels has the labels added by meltcd
+func (n Network) CreateOptions(labels map[string]string) types.NetworkCreate {
+	driver := n.Driver
+	if driver == "" {
+		driver = "overlay"
+	}
+
+	options := types.NetworkCreate{
+		Driver:     driver,
+		Scope:      "swarm",
+		Options:    n.DriverOpts,
+		Internal:   n.Internal,
+		Attachable: n.Attachable,
+		EnableIPv6: n.EnableIPv6,
+		Labels:     map[string]string{},
+	}
+
+	for k, v := range n.Labels {
+		options.Labels[k] = v
+	}
+	for k, v := range labels {
+		options.Labels[k] = v
+	}
+
+	if n.Ipam.Driver != "" || len(n.Ipam.Config) != 0 || len(n.Ipam.Options) != 0 {
+		options.IPAM = &network.IPAM{
+			Driver:  n.Ipam.Driver,
+			Options: n.Ipam.Options,
+		}
+		for _, c := range n.Ipam.Config {
+			options.IPAM.Config = append(options.IPAM.Config, network.IPAMConfig{
+				Subnet:     c.Subnet,
+				IPRange:    c.IPRange,
+				Gateway:    c.Gateway,
+				AuxAddress: c.AuxAddresses,
+			})
+		}
+	}
+
+	return options
+}
+
+// networkAttachments attaches the service to its networks, networkIDs is the
+// name of the network in the service file to the docker network id
+func (s Service) networkAttachments(serviceName string, networkIDs map[string]string) ([]swarm.NetworkAttachmentConfig, error) {
+	var attachments []swarm.NetworkAttachmentConfig
+
+	for name, options := range s.serviceNetworks() {
+		id, ok := networkIDs[name]
+		if !ok {
+			return nil, fmt.Errorf("network %s is not found", name)
+		}
+
+		// the service is reachable by its name in all of its networks
+		aliases := []string{serviceName}
+		for _, alias := range options.Aliases {
+			if !slices.Contains(aliases, alias) {
+				aliases = append(aliases, alias)
+			}
+		}
+
+		attachments = append(attachments, swarm.NetworkAttachmentConfig{
+			Target:     id,
+			Aliases:    aliases,
+			DriverOpts: options.DriverOpts,
+		})
+	}
+
+	sort.Slice(attachments, func(i, j int) bool {
+		return attachments[i].Target < attachments[j].Target
+	})
+
+	return attachments, nil
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"slices"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+const networksFile = `
+services:
+  web:
+    image: nginx
+    networks:
+      - front
+      - proxy
+  api:
+    image: api
+    networks:
+      front:
+        aliases: [backend]
+      back:
+  worker:
+    image: worker
+networks:
+  front:
+    driver: overlay
+    attachable: true
+    driver_opts:
+      encrypted: "true"
+    ipam:
+      config:
+        - subnet: 10.10.0.0/24
+    labels:
+      - team=web
+  back:
+    name: shared-back
+    internal: true
+  proxy:
+    external: true
+  unused:
+    external:
+      name: legacy
+`
+
+func TestNetworks(t *testing.T) {
+	var d DockerSwarm
+	if err := yaml.Unmarshal([]byte(networksFile), &d); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	used, err := d.UsedNetworks()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	names := map[string]string{}
+	for name, n := range used {
+		names[name] = n.DockerName("app", name)
+	}
+
+	want := map[string]string{
+		"front":   "app_front",
+		"back":    "shared-back",
+		"proxy":   "proxy",
+		"default": "app_default",
+	}
+	if len(names) != len(want) {
+		t.Errorf("used networks = %v, want %v", names, want)
+	}
+	for name, dockerName := range want {
+		if names[name] != dockerName {
+			t.Errorf("docker name of %s = %q, want %q", name, names[name], dockerName)
+		}
+	}
+
+	if name := d.Networks["unused"].DockerName("app", "unused"); name != "legacy" {
+		t.Errorf("docker name of legacy external network = %q", name)
+	}
+
+	options := used["front"].CreateOptions(map[string]string{"com.docker.stack.namespace": "app"})
+	if !options.Attachable || options.Options["encrypted"] != "true" || options.IPAM.Config[0].Subnet != "10.10.0.0/24" ||
+		options.Labels["team"] != "web" || options.Labels["com.docker.stack.namespace"] != "app" {
+		t.Errorf("invalid create options %+v", options)
+	}
+
+	if !used["back"].CreateOptions(nil).Internal {
+		t.Error("back network should be internal")
+	}
+
+	ids := map[string]string{"front": "id-front", "back": "id-back", "proxy": "id-proxy", "default": "id-default"}
+
+	api, err := d.Services["api"].networkAttachments("api", ids)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(api) != 2 || api[0].Target != "id-back" || api[1].Target != "id-front" ||
+		!slices.Equal(api[1].Aliases, []string{"api", "backend"}) {
+		t.Errorf("invalid attachments of api %+v", api)
+	}
+
+	worker, err := d.Services["worker"].networkAttachments("worker", ids)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(worker) != 1 || worker[0].Target != "id-default" {
+		t.Errorf("worker should be attached to the default network %+v", worker)
+	}
+}
+
+func TestUndefinedNetwork(t *testing.T) {
+	var d DockerSwarm
+	if err := yaml.Unmarshal([]byte("services: {web: {image: nginx, networks: [missing]}}"), &d); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, err := d.UsedNetworks(); err == nil {
+		t.Error("expected error for undefined network")
+	}
+}
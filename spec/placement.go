@@ -0,0 +1,115 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/docker/docker/api/types/swarm"
+)
+
+type Placement struct {
+	Constraints        []string              `yaml:"constraints"` // like "node.role == manager"
+	Preferences        []PlacementPreference `yaml:"preferences"`
+	MaxReplicasPerNode uint64                `yaml:"max_replicas_per_node"`
+}
+
+type PlacementPreference struct {
+	Spread string `yaml:"spread"` // like "node.labels.zone"
+}
+
+// constraintPattern is "<attribute> ==|!= <value>"
+var constraintPattern = regexp.MustCompile(`^\s*([\w.\-]+)\s*(==|!=)\s*(\S.*?)\s*$`)
+
+// constraintAttributes are the node attributes a constraint can use,
+// labels are the node.labels.<name> and engine.labels.<name> prefixes
+var constraintAttributes = []string{
+	"node.id",
+	"node.hostname",
+	"node.role",
+	"node.platform.os",
+	"node.platform.arch",
+}
+
+var labelPrefixes = []string{"node.labels.", "engine.labels."}
+
+func (p Placement) swarmPlacement(global bool) (*swarm.Placement, error) {
+	if len(p.Constraints) == 0 && len(p.Preferences) == 0 && p.MaxReplicasPerNode == 0 {
+		return nil, nil
+	}
+
+	if global && p.MaxReplicasPerNode != 0 {
+		return nil, fmt.Errorf("max_replicas_per_node can not be used with global mode")
+	}
+
+	placement := swarm.Placement{
+		MaxReplicas: p.MaxReplicasPerNode,
+	}
+
+	for _, c := range p.Constraints {
+		constraint, err := parseConstraint(c)
+		if err != nil {
+			return nil, err
+		}
+		placement.Constraints = append(placement.Constraints, constraint)
+	}
+
+	for _, pref := range p.Preferences {
+		spread := strings.TrimSpace(pref.Spread)
+		if !isLabel(spread) {
+			return nil, fmt.Errorf("invalid placement preference spread %q, it must be a node.labels.<name> or engine.labels.<name>", pref.Spread)
+		}
+
+		placement.Preferences = append(placement.Preferences, swarm.PlacementPreference{
+			Spread: &swarm.SpreadOver{SpreadDescriptor: spread},
+		})
+	}
+
+	return &placement, nil
+}
+
+// parseConstraint validates the constraint and returns it in the form docker uses ("node.role==manager")
+func parseConstraint(c string) (string, error) {
+	match := constraintPattern.FindStringSubmatch(c)
+	if match == nil {
+		return "", fmt.Errorf("invalid placement constraint %q, expected <attribute> == <value> or <attribute> != <value>", c)
+	}
+
+	attribute, operator, value := match[1], match[2], match[3]
+
+	if !isLabel(attribute) && !slices.Contains(constraintAttributes, attribute) {
+		return "", fmt.Errorf("invalid placement constraint %q, unknown attribute %q", c, attribute)
+	}
+
+	if attribute == "node.role" && value != "manager" && value != "worker" {
+		return "", fmt.Errorf("invalid placement constraint %q, node.role must be manager or worker", c)
+	}
+
+	return attribute + operator + value, nil
+}
+
+func isLabel(attribute string) bool {
+	for _, prefix := range labelPrefixes {
+		if name, ok := strings.CutPrefix(attribute, prefix); ok && name != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPlacement(t *testing.T) {
+	p := Placement{
+		Constraints:        []string{"node.role == manager", "node.labels.db==true", " engine.labels.os != windows "},
+		Preferences:        []PlacementPreference{{Spread: "node.labels.zone"}},
+		MaxReplicasPerNode: 2,
+	}
+
+	placement, err := p.swarmPlacement(false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	want := []string{"node.role==manager", "node.labels.db==true", "engine.labels.os!=windows"}
+	if !slices.Equal(placement.Constraints, want) {
+		t.Errorf("constraints = %v, want %v", placement.Constraints, want)
+	}
+
+	if len(placement.Preferences) != 1 || placement.Preferences[0].Spread.SpreadDescriptor != "node.labels.zone" {
+		t.Error("invalid preferences", placement.Preferences)
+	}
+
+	if placement.MaxReplicas != 2 {
+		t.Error("invalid max replicas", placement.MaxReplicas)
+	}
+
+	if placement, _ := (Placement{}).swarmPlacement(false); placement != nil {
+		t.Error("placement should not be set when empty")
+	}
+}
+
+func TestInvalidPlacement(t *testing.T) {
+	invalid := []Placement{
+		{Constraints: []string{"node.role"}},
+		{Constraints: []string{"node.role = manager"}},
+		{Constraints: []string{"node.role == master"}},
+		{Constraints: []string{"node.colour == red"}},
+		{Constraints: []string{"node.labels. == x"}},
+		{Constraints: []string{"node.hostname =="}},
+		{Preferences: []PlacementPreference{{Spread: "zone"}}},
+		{Preferences: []PlacementPreference{{}}},
+	}
+
+	for _, p := range invalid {
+		if _, err := p.swarmPlacement(false); err == nil {
+			t.Errorf("expected error for %+v", p)
+		}
+	}
+
+	if _, err := (Placement{MaxReplicasPerNode: 1}).swarmPlacement(true); err == nil {
+		t.Error("max_replicas_per_node should not be allowed with global mode")
+	}
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"log/slog"
+
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// Port is a port of the service in the compose short syntax ("8080:80/udp")
+// or long syntax (map with target, published, protocol and mode)
+type Port struct {
+	Name      string
+	Target    string // container port or range like "8080-8081"
+	Published string // empty when the port is not published on a fixed port
+	HostIP    string // not supported by swarm, it is ignored
+	Protocol  string // tcp (default), udp or sctp
+	Mode      string // ingress (default) or host
+}
+
+type longPort struct {
+	Name      string      `yaml:"name"`
+	Target    interface{} `yaml:"target"`
+	Published interface{} `yaml:"published"`
+	HostIP    string      `yaml:"host_ip"`
+	Protocol  string      `yaml:"protocol"`
+	Mode      string      `yaml:"mode"`
+}
+
+func (p *Port) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var short interface{}
+	if err := unmarshal(&short); err != nil {
+		return err
+	}
+
+	switch v := short.(type) {
+	case string:
+		port, err := parsePort(v)
+		if err != nil {
+			return err
+		}
+		*p = port
+		return nil
+	case int:
+		*p = Port{Target: strconv.Itoa(v)}
+		return nil
+	}
+
+	var long longPort
+	if err := unmarshal(&long); err != nil {
+		return fmt.Errorf("invalid port: %w", err)
+	}
+
+	if long.Target == nil {
+		return fmt.Errorf("invalid port: target is required")
+	}
+
+	*p = Port{
+		Name:     long.Name,
+		Target:   fmt.Sprint(long.Target),
+		HostIP:   long.HostIP,
+		Protocol: long.Protocol,
+		Mode:     long.Mode,
+	}
+	if long.Published != nil {
+		p.Published = fmt.Sprint(long.Published)
+	}
+
+	return nil
+}
+
+// parsePort parses the short syntax [HOST_IP:][PUBLISHED:]TARGET[/PROTOCOL],
+// like "80", "8080:80", "127.0.0.1:8080:80", "[::1]:8080:80" or "9090-9091:8080-8081/udp"
+func parsePort(s string) (Port, error) {
+	var port Port
+	value := strings.TrimSpace(s)
+
+	if rest, protocol, ok := strings.Cut(value, "/"); ok {
+		value = rest
+		port.Protocol = protocol
+	}
+
+	// IPv6 host ip in brackets
+	if strings.HasPrefix(value, "[") {
+		end := strings.Index(value, "]")
+		if end == -1 || !strings.HasPrefix(value[end+1:], ":") {
+			return Port{}, fmt.Errorf("invalid port %q", s)
+		}
+		port.HostIP = value[1:end]
+		value = value[end+2:]
+	}
+
+	parts := strings.Split(value, ":")
+	switch {
+	case len(parts) == 1 && port.HostIP == "":
+		port.Target = parts[0]
+	case len(parts) == 2:
+		port.Published, port.Target = parts[0], parts[1]
+	case len(parts) == 3 && port.HostIP == "":
+		port.HostIP, port.Published, port.Target = parts[0], parts[1], parts[2]
+	default:
+		return Port{}, fmt.Errorf("invalid port %q, expected [HOST_IP:][PUBLISHED:]TARGET[/PROTOCOL]", s)
+	}
+
+	if port.Target == "" {
+		return Port{}, fmt.Errorf("invalid port %q, container port is required", s)
+	}
+
+	return port, nil
+}
+
+// swarmPorts returns the port configs of the port, a range gives a config for every port in it
+func (p Port) swarmPorts() ([]swarm.PortConfig, error) {
+	protocol := swarm.PortConfigProtocol(strings.ToLower(p.Protocol))
+	switch protocol {
+	case "":
+		protocol = swarm.PortConfigProtocolTCP
+	case swarm.PortConfigProtocolTCP, swarm.PortConfigProtocolUDP, swarm.PortConfigProtocolSCTP:
+	default:
+		return nil, fmt.Errorf("invalid port protocol %q, it must be tcp, udp or sctp", p.Protocol)
+	}
+
+	mode := swarm.PortConfigPublishMode(strings.ToLower(p.Mode))
+	switch mode {
+	case "":
+		mode = swarm.PortConfigPublishModeIngress
+	case swarm.PortConfigPublishModeIngress, swarm.PortConfigPublishModeHost:
+	default:
+		return nil, fmt.Errorf("invalid port mode %q, it must be ingress or host", p.Mode)
+	}
+
+	targets, err := portRange(p.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	var published []uint32
+	if p.Published != "" {
+		published, err = portRange(p.Published)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(published) != len(targets) {
+			return nil, fmt.Errorf("published ports %q and container ports %q are not of the same length", p.Published, p.Target)
+		}
+	}
+
+	if p.HostIP != "" {
+		slog.Warn("host ip of the port is not supported by swarm, ignoring it", "host_ip", p.HostIP, "port", p.Target)
+	}
+
+	configs := make([]swarm.PortConfig, 0, len(targets))
+	for i, target := range targets {
+		config := swarm.PortConfig{
+			Name:        p.Name,
+			Protocol:    protocol,
+			TargetPort:  target,
+			PublishMode: mode,
+		}
+		if published != nil {
+			config.PublishedPort = published[i]
+		}
+
+		configs = append(configs, config)
+	}
+
+	return configs, nil
+}
+
+// portRange parses a port ("80") or a range of ports ("8080-8085")
+func portRange(s string) ([]uint32, error) {
+	start, end, isRange := strings.Cut(strings.TrimSpace(s), "-")
+
+	first, err := parsePortNumber(start)
+	if err != nil {
+		return nil, err
+	}
+
+	last := first
+	if isRange {
+		last, err = parsePortNumber(end)
+		if err != nil {
+			return nil, err
+		}
+
+		if last < first {
+			return nil, fmt.Errorf("invalid port range %q", s)
+		}
+	}
+
+	ports := make([]uint32, 0, last-first+1)
+	for port := first; port <= last; port++ {
+		ports = append(ports, port)
+	}
+
+	return ports, nil
+}
+
+func parsePortNumber(s string) (uint32, error) {
+	port, err := strconv.ParseUint(strings.TrimSpace(s), 10, 16)
+	if err != nil || port == 0 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+
+	return uint32(port), nil
+}
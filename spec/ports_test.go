@@ -0,0 +1,98 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+	"gopkg.in/yaml.v2"
+)
+
+func TestPorts(t *testing.T) {
+	tcp := swarm.PortConfigProtocolTCP
+	udp := swarm.PortConfigProtocolUDP
+	ingress := swarm.PortConfigPublishModeIngress
+	host := swarm.PortConfigPublishModeHost
+
+	cases := []struct {
+		yaml string
+		want []swarm.PortConfig
+	}{
+		{`"3000"`, []swarm.PortConfig{{Protocol: tcp, TargetPort: 3000, PublishMode: ingress}}},
+		{`3000`, []swarm.PortConfig{{Protocol: tcp, TargetPort: 3000, PublishMode: ingress}}},
+		{`"8080:80"`, []swarm.PortConfig{{Protocol: tcp, TargetPort: 80, PublishedPort: 8080, PublishMode: ingress}}},
+		{`"53:53/udp"`, []swarm.PortConfig{{Protocol: udp, TargetPort: 53, PublishedPort: 53, PublishMode: ingress}}},
+		{`"127.0.0.1:8001:8001"`, []swarm.PortConfig{{Protocol: tcp, TargetPort: 8001, PublishedPort: 8001, PublishMode: ingress}}},
+		{`"[::1]:6001:6001"`, []swarm.PortConfig{{Protocol: tcp, TargetPort: 6001, PublishedPort: 6001, PublishMode: ingress}}},
+		{`"9090-9091:8080-8081"`, []swarm.PortConfig{
+			{Protocol: tcp, TargetPort: 8080, PublishedPort: 9090, PublishMode: ingress},
+			{Protocol: tcp, TargetPort: 8081, PublishedPort: 9091, PublishMode: ingress},
+		}},
+		{`"3000-3001"`, []swarm.PortConfig{
+			{Protocol: tcp, TargetPort: 3000, PublishMode: ingress},
+			{Protocol: tcp, TargetPort: 3001, PublishMode: ingress},
+		}},
+		{`{target: 80, published: 8080, protocol: udp, mode: host}`, []swarm.PortConfig{{Protocol: udp, TargetPort: 80, PublishedPort: 8080, PublishMode: host}}},
+		{`{target: 80, published: "8080", name: web}`, []swarm.PortConfig{{Name: "web", Protocol: tcp, TargetPort: 80, PublishedPort: 8080, PublishMode: ingress}}},
+	}
+
+	for _, c := range cases {
+		var port Port
+		if err := yaml.Unmarshal([]byte(c.yaml), &port); err != nil {
+			t.Errorf("failed to parse %s: %s", c.yaml, err.Error())
+			continue
+		}
+
+		got, err := port.swarmPorts()
+		if err != nil {
+			t.Errorf("failed to convert %s: %s", c.yaml, err.Error())
+			continue
+		}
+
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ports of %s = %+v, want %+v", c.yaml, got, c.want)
+		}
+	}
+}
+
+func TestInvalidPorts(t *testing.T) {
+	invalid := []string{
+		`"1:2:3:4"`,
+		`"8080:"`,
+		`"abc"`,
+		`"0"`,
+		`"70000"`,
+		`"8080:80/icmp"`,
+		`"9090-9092:8080-8081"`,
+		`"8081-8080"`,
+		`{target: 80, mode: global}`,
+		`{published: 80}`,
+	}
+
+	for _, s := range invalid {
+		var port Port
+		if err := yaml.Unmarshal([]byte(s), &port); err != nil {
+			continue
+		}
+
+		if _, err := port.swarmPorts(); err == nil {
+			t.Errorf("expected error for port %s", s)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	units "github.com/docker/go-units"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+)
+
+// Volume is a named volume of the service file
+type Volume struct {
+	Name       string            `yaml:"name"`
+	Driver     string            `yaml:"driver"`
+	DriverOpts map[string]string `yaml:"driver_opts"`
+	Options    map[string]string `yaml:"options"` // legacy driver options, driver_opts take precedence
+	External   External          `yaml:"external"`
+	Labels     Mapping           `yaml:"labels"`
+}
+
+// DockerName is the name of the volume in docker, unlike networks the name of the volume is not
+// prefixed with the application name (volumes hold data, so existing volumes keep their name)
+func (v Volume) DockerName(name string) string {
+	switch {
+	case v.External.Name != "":
+		return v.External.Name
+	case v.Name != "":
+		return v.Name
+	}
+
+	return name
+}
+
+// DriverOptions are the options of the volume driver
+func (v Volume) DriverOptions() map[string]string {
+	if len(v.Options) == 0 {
+		return v.DriverOpts
+	}
+
+	options := map[string]string{}
+	for k, val := range v.Options {
+		options[k] = val
+	}
+	for k, val := range v.DriverOpts {
+		options[k] = val
+	}
+	return options
+}
+
+// CreateOptions are the options to create the volume, labels has the labels added by meltcd
+func (v Volume) CreateOptions(name string, labels map[string]string) volume.CreateOptions {
+	return volume.CreateOptions{
+		Name:       v.DockerName(name),
+		Driver:     v.Driver,
+		DriverOpts: v.DriverOptions(),
+		Labels:     v.labels(labels),
+	}
+}
+
+func (v Volume) labels(labels map[string]string) map[string]string {
+	result := map[string]string{}
+	for k, val := range v.Labels {
+		result[k] = val
+	}
+	for k, val := range labels {
+		result[k] = val
+	}
+	return result
+}
+
+// ServiceVolume is a mount of the service in the compose short syntax
+// ("data:/var/lib/data:ro") or long syntax (map with type, source, target and options)
+type ServiceVolume struct {
+	Type        mount.Type           `yaml:"type"` // volume, bind or tmpfs
+	Source      string               `yaml:"source"`
+	Target      string               `yaml:"target"`
+	ReadOnly    bool                 `yaml:"read_only"`
+	Consistency string               `yaml:"consistency"`
+	Bind        *ServiceVolumeBind   `yaml:"bind"`
+	Volume      *ServiceVolumeVolume `yaml:"volume"`
+	Tmpfs       *ServiceVolumeTmpfs  `yaml:"tmpfs"`
+}
+
+type ServiceVolumeBind struct {
+	Propagation    string `yaml:"propagation"`
+	CreateHostPath bool   `yaml:"create_host_path"`
+	SELinux        string `yaml:"selinux"` // not supported by swarm, it is ignored
+}
+
+type ServiceVolumeVolume struct {
+	NoCopy bool `yaml:"nocopy"`
+}
+
+type ServiceVolumeTmpfs struct {
+	Size interface{} `yaml:"size"` // bytes or a size like "64m"
+	Mode interface{} `yaml:"mode"` // number (01777) or octal string ("1777")
+}
+
+type longServiceVolume ServiceVolume
+
+func (v *ServiceVolume) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var short string
+	if err := unmarshal(&short); err == nil {
+		volume, err := parseServiceVolume(short)
+		if err != nil {
+			return err
+		}
+		*v = volume
+		return nil
+	}
+
+	var long longServiceVolume
+	if err := unmarshal(&long); err != nil {
+		return fmt.Errorf("invalid volume: %w", err)
+	}
+
+	if long.Type == "" {
+		return fmt.Errorf("invalid volume %q: type is required", long.Target)
+	}
+
+	*v = ServiceVolume(long)
+	return nil
+}
+
+// parseServiceVolume parses the short syntax [SOURCE:]TARGET[:MODE], the source is a
+// bind mount when it is a path (starting with ".", "~" or "/") and a named volume otherwise,
+// MODE is a comma separated list of ro, rw, nocopy, z, Z and the bind propagation
+func parseServiceVolume(s string) (ServiceVolume, error) {
+	var v ServiceVolume
+	var mode string
+
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	switch len(parts) {
+	case 1:
+		// anonymous volume
+		v.Target = parts[0]
+	case 2:
+		v.Source, v.Target = parts[0], parts[1]
+	case 3:
+		v.Source, v.Target, mode = parts[0], parts[1], parts[2]
+	default:
+		return ServiceVolume{}, fmt.Errorf("invalid volume %q, expected [SOURCE:]TARGET[:MODE]", s)
+	}
+
+	v.Type = mount.TypeVolume
+	if isHostPath(v.Source) {
+		v.Type = mount.TypeBind
+	}
+
+	if mode == "" {
+		return v, nil
+	}
+
+	for _, option := range strings.Split(mode, ",") {
+		switch option {
+		case "ro":
+			v.ReadOnly = true
+		case "rw":
+			v.ReadOnly = false
+		case "nocopy":
+			if v.Type != mount.TypeVolume {
+				return ServiceVolume{}, fmt.Errorf("invalid volume %q, nocopy is only supported by volumes", s)
+			}
+			v.Volume = &ServiceVolumeVolume{NoCopy: true}
+		case "z", "Z":
+			if v.Type != mount.TypeBind {
+				return ServiceVolume{}, fmt.Errorf("invalid volume %q, %s is only supported by bind mounts", s, option)
+			}
+			if v.Bind == nil {
+				v.Bind = &ServiceVolumeBind{}
+			}
+			v.Bind.SELinux = option
+		case string(mount.PropagationRPrivate), string(mount.PropagationPrivate),
+			string(mount.PropagationRShared), string(mount.PropagationShared),
+			string(mount.PropagationRSlave), string(mount.PropagationSlave):
+			if v.Type != mount.TypeBind {
+				return ServiceVolume{}, fmt.Errorf("invalid volume %q, %s is only supported by bind mounts", s, option)
+			}
+			if v.Bind == nil {
+				v.Bind = &ServiceVolumeBind{}
+			}
+			v.Bind.Propagation = option
+		default:
+			return ServiceVolume{}, fmt.Errorf("invalid volume %q, unknown mode %q", s, option)
+		}
+	}
+
+	return v, nil
+}
+
+func isHostPath(source string) bool {
+	return strings.HasPrefix(source, ".") ||
+		strings.HasPrefix(source, "~") ||
+		strings.HasPrefix(source, "/")
+}
+
+// mount returns the swarm mount of the volume, named volumes defined in the top level volumes
+// use their docker name and get the driver, driver options and labels of the definition,
+// so swarm creates them the same way on every node
+func (v ServiceVolume) mount(appName string, volumes map[string]Volume) (mount.Mount, error) {
+	if !path.IsAbs(v.Target) {
+		return mount.Mount{}, fmt.Errorf("invalid volume target %q, it must be an absolute path", v.Target)
+	}
+
+	m := mount.Mount{
+		Type:     v.Type,
+		Source:   v.Source,
+		Target:   v.Target,
+		ReadOnly: v.ReadOnly,
+	}
+
+	switch mount.Consistency(v.Consistency) {
+	case "", mount.ConsistencyDefault, mount.ConsistencyFull, mount.ConsistencyCached, mount.ConsistencyDelegated:
+		m.Consistency = mount.Consistency(v.Consistency)
+	default:
+		return mount.Mount{}, fmt.Errorf("invalid volume consistency %q", v.Consistency)
+	}
+
+	if v.Bind != nil && m.Type != mount.TypeBind {
+		return mount.Mount{}, fmt.Errorf("volume %s: bind options are only supported by bind mounts", v.Target)
+	}
+	if v.Volume != nil && m.Type != mount.TypeVolume {
+		return mount.Mount{}, fmt.Errorf("volume %s: volume options are only supported by volumes", v.Target)
+	}
+	if v.Tmpfs != nil && m.Type != mount.TypeTmpfs {
+		return mount.Mount{}, fmt.Errorf("volume %s: tmpfs options are only supported by tmpfs mounts", v.Target)
+	}
+
+	switch m.Type {
+	case mount.TypeVolume:
+		options := &mount.VolumeOptions{}
+		if v.Volume != nil {
+			options.NoCopy = v.Volume.NoCopy
+		}
+
+		if volume, ok := volumes[v.Source]; ok && v.Source != "" {
+			m.Source = volume.DockerName(v.Source)
+
+			if !volume.External.External {
+				if volume.Driver != "" || len(volume.DriverOptions()) != 0 {
+					options.DriverConfig = &mount.Driver{
+						Name:    volume.Driver,
+						Options: volume.DriverOptions(),
+					}
+				}
+				options.Labels = volume.labels(map[string]string{
+					"com.docker.stack.namespace": appName,
+				})
+			}
+		}
+
+		if options.NoCopy || options.DriverConfig != nil || len(options.Labels) != 0 {
+			m.VolumeOptions = options
+		}
+	case mount.TypeBind:
+		if v.Source == "" {
+			return mount.Mount{}, fmt.Errorf("volume %s: bind mount requires a source", v.Target)
+		}
+
+		absPath, err := normalizeFilePath(v.Source)
+		if err != nil {
+			return mount.Mount{}, err
+		}
+		m.Source = absPath
+
+		if v.Bind != nil && (v.Bind.Propagation != "" || v.Bind.CreateHostPath) {
+			switch mount.Propagation(v.Bind.Propagation) {
+			case "", mount.PropagationRPrivate, mount.PropagationPrivate,
+				mount.PropagationRShared, mount.PropagationShared,
+				mount.PropagationRSlave, mount.PropagationSlave:
+			default:
+				return mount.Mount{}, fmt.Errorf("volume %s: invalid bind propagation %q", v.Target, v.Bind.Propagation)
+			}
+
+			m.BindOptions = &mount.BindOptions{
+				Propagation:      mount.Propagation(v.Bind.Propagation),
+				CreateMountpoint: v.Bind.CreateHostPath,
+			}
+		}
+	case mount.TypeTmpfs:
+		if v.Source != "" {
+			return mount.Mount{}, fmt.Errorf("volume %s: tmpfs mount can not have a source", v.Target)
+		}
+
+		if v.Tmpfs != nil {
+			options, err := v.Tmpfs.tmpfsOptions()
+			if err != nil {
+				return mount.Mount{}, fmt.Errorf("volume %s: %w", v.Target, err)
+			}
+			m.TmpfsOptions = options
+		}
+	default:
+		return mount.Mount{}, fmt.Errorf("volume %s: unsupported type %q, it must be volume, bind or tmpfs", v.Target, v.Type)
+	}
+
+	return m, nil
+}
+
+func (t ServiceVolumeTmpfs) tmpfsOptions() (*mount.TmpfsOptions, error) {
+	var options mount.TmpfsOptions
+
+	switch size := t.Size.(type) {
+	case nil:
+	case int:
+		options.SizeBytes = int64(size)
+	case string:
+		bytes, err := units.RAMInBytes(size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tmpfs size %q", size)
+		}
+		options.SizeBytes = bytes
+	default:
+		return nil, fmt.Errorf("invalid tmpfs size %v", size)
+	}
+
+	switch mode := t.Mode.(type) {
+	case nil:
+	case int:
+		options.Mode = os.FileMode(mode)
+	case string:
+		value, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tmpfs mode %q", mode)
+		}
+		options.Mode = os.FileMode(value)
+	default:
+		return nil, fmt.Errorf("invalid tmpfs mode %v", mode)
+	}
+
+	return &options, nil
+}
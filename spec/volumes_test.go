@@ -0,0 +1,190 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+	"gopkg.in/yaml.v2"
+)
+
+func TestVolumes(t *testing.T) {
+	volumes := map[string]Volume{
+		"data":   {Driver: "local", DriverOpts: map[string]string{"type": "nfs", "device": ":/exports"}},
+		"cache":  {Name: "shared-cache", Labels: Mapping{"backup": "false"}},
+		"legacy": {External: External{External: true}, Driver: "local"},
+		"logs":   {External: External{External: true, Name: "host-logs"}},
+	}
+	labels := map[string]string{"com.docker.stack.namespace": "app"}
+	cacheLabels := map[string]string{"com.docker.stack.namespace": "app", "backup": "false"}
+
+	cases := []struct {
+		yaml string
+		want mount.Mount
+	}{
+		{`"/var/lib/data"`, mount.Mount{Type: mount.TypeVolume, Target: "/var/lib/data"}},
+		{`"undefined:/undefined"`, mount.Mount{Type: mount.TypeVolume, Source: "undefined", Target: "/undefined"}},
+		{`"cache:/cache"`, mount.Mount{Type: mount.TypeVolume, Source: "shared-cache", Target: "/cache", VolumeOptions: &mount.VolumeOptions{Labels: cacheLabels}}},
+		{`"data:/var/lib/data:ro"`, mount.Mount{
+			Type: mount.TypeVolume, Source: "data", Target: "/var/lib/data", ReadOnly: true,
+			VolumeOptions: &mount.VolumeOptions{
+				Labels:       labels,
+				DriverConfig: &mount.Driver{Name: "local", Options: map[string]string{"type": "nfs", "device": ":/exports"}},
+			},
+		}},
+		{`"cache:/cache:nocopy"`, mount.Mount{Type: mount.TypeVolume, Source: "shared-cache", Target: "/cache", VolumeOptions: &mount.VolumeOptions{NoCopy: true, Labels: cacheLabels}}},
+		{`"legacy:/legacy"`, mount.Mount{Type: mount.TypeVolume, Source: "legacy", Target: "/legacy"}},
+		{`"logs:/logs:ro"`, mount.Mount{Type: mount.TypeVolume, Source: "host-logs", Target: "/logs", ReadOnly: true}},
+		{`"/srv/config:/etc/app:ro,rslave"`, mount.Mount{
+			Type: mount.TypeBind, Source: "/srv/config", Target: "/etc/app", ReadOnly: true,
+			BindOptions: &mount.BindOptions{Propagation: mount.PropagationRSlave},
+		}},
+		{`"/srv/config:/etc/app:z"`, mount.Mount{Type: mount.TypeBind, Source: "/srv/config", Target: "/etc/app"}},
+		{`{type: volume, source: cache, target: /cache, read_only: true, volume: {nocopy: true}}`, mount.Mount{
+			Type: mount.TypeVolume, Source: "shared-cache", Target: "/cache", ReadOnly: true,
+			VolumeOptions: &mount.VolumeOptions{NoCopy: true, Labels: cacheLabels},
+		}},
+		{`{type: bind, source: /srv/config, target: /etc/app, bind: {propagation: shared, create_host_path: true}}`, mount.Mount{
+			Type: mount.TypeBind, Source: "/srv/config", Target: "/etc/app",
+			BindOptions: &mount.BindOptions{Propagation: mount.PropagationShared, CreateMountpoint: true},
+		}},
+		{`{type: tmpfs, target: /tmp, tmpfs: {size: 64m, mode: "1777"}}`, mount.Mount{
+			Type: mount.TypeTmpfs, Target: "/tmp",
+			TmpfsOptions: &mount.TmpfsOptions{SizeBytes: 64 * 1024 * 1024, Mode: 01777},
+		}},
+		{`{type: tmpfs, target: /run, tmpfs: {size: 1024, mode: 0700}}`, mount.Mount{
+			Type: mount.TypeTmpfs, Target: "/run",
+			TmpfsOptions: &mount.TmpfsOptions{SizeBytes: 1024, Mode: 0700},
+		}},
+		{`{type: bind, source: /srv/cache, target: /cache, consistency: cached}`, mount.Mount{
+			Type: mount.TypeBind, Source: "/srv/cache", Target: "/cache", Consistency: mount.ConsistencyCached,
+		}},
+	}
+
+	for _, c := range cases {
+		var volume ServiceVolume
+		if err := yaml.Unmarshal([]byte(c.yaml), &volume); err != nil {
+			t.Errorf("failed to parse %s: %s", c.yaml, err.Error())
+			continue
+		}
+
+		got, err := volume.mount("app", volumes)
+		if err != nil {
+			t.Errorf("failed to convert %s: %s", c.yaml, err.Error())
+			continue
+		}
+
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("mount of %s = %+v, want %+v", c.yaml, got, c.want)
+		}
+	}
+}
+
+func TestRelativeBindVolume(t *testing.T) {
+	var volume ServiceVolume
+	if err := yaml.Unmarshal([]byte(`"./config:/etc/app:ro"`), &volume); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := volume.mount("app", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := normalizeFilePath("./config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Type != mount.TypeBind || got.Source != want || !got.ReadOnly {
+		t.Errorf("mount = %+v, want read only bind of %s", got, want)
+	}
+}
+
+func TestInvalidVolumes(t *testing.T) {
+	invalid := []string{
+		`"a:b:c:d"`,
+		`"data:relative/path"`,
+		`"data:/data:rx"`,
+		`"data:/data:rshared"`,
+		`"/srv:/data:nocopy"`,
+		`{source: data, target: /data}`,
+		`{type: npipe, source: data, target: /data}`,
+		`{type: tmpfs, source: data, target: /tmp}`,
+		`{type: bind, target: /data}`,
+		`{type: volume, source: data, target: /data, bind: {propagation: shared}}`,
+		`{type: bind, source: /srv, target: /data, bind: {propagation: up}}`,
+		`{type: tmpfs, target: /tmp, tmpfs: {size: huge}}`,
+		`{type: tmpfs, target: /tmp, tmpfs: {mode: "999"}}`,
+		`{type: volume, source: data, target: /data, consistency: eventual}`,
+	}
+
+	for _, s := range invalid {
+		var volume ServiceVolume
+		if err := yaml.Unmarshal([]byte(s), &volume); err != nil {
+			continue
+		}
+
+		if _, err := volume.mount("app", nil); err == nil {
+			t.Errorf("expected error for volume %s", s)
+		}
+	}
+}
+
+func TestVolumeDefinition(t *testing.T) {
+	var d DockerSwarm
+	err := yaml.Unmarshal([]byte(`
+volumes:
+  data:
+    driver: local
+    driver_opts:
+      type: nfs
+    options:
+      type: tmpfs
+      o: addr=10.0.0.1
+    labels:
+      - backup=daily
+  named:
+    name: app-data
+  external:
+    external: true
+`), &d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := d.Volumes["data"].CreateOptions("data", map[string]string{"com.docker.stack.namespace": "app"})
+	want := volume.CreateOptions{
+		Name:       "data",
+		Driver:     "local",
+		DriverOpts: map[string]string{"type": "nfs", "o": "addr=10.0.0.1"},
+		Labels:     map[string]string{"backup": "daily", "com.docker.stack.namespace": "app"},
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("create options = %+v, want %+v", data, want)
+	}
+
+	if name := d.Volumes["named"].DockerName("named"); name != "app-data" {
+		t.Errorf("docker name = %q, want app-data", name)
+	}
+	if !d.Volumes["external"].External.External {
+		t.Error("volume must be external")
+	}
+}
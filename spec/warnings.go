@@ -0,0 +1,102 @@
+/*
+Copyright 2023 - PRESENT Meltred
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spec
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ignoredFields are the alternatives of compose fields which swarm does not support,
+// they are added to the warning
+var ignoredFields = map[string]string{
+	"build":           "swarm deploys the image, build it in CI and push it to a registry",
+	"container_name":  "swarm names the containers of a service",
+	"depends_on":      "swarm starts all services together, use healthchecks and retries",
+	"restart":         "use deploy.restart_policy",
+	"links":           "services on the same network can reach each other by name",
+	"external_links":  "services on the same network can reach each other by name",
+	"network_mode":    "use networks",
+	"shm_size":        "use a tmpfs mount",
+	"mem_limit":       "use deploy.resources.limits.memory",
+	"mem_reservation": "use deploy.resources.reservations.memory",
+	"cpus":            "use deploy.resources.limits.cpus",
+	"cpu_shares":      "use deploy.resources",
+	"cpu_quota":       "use deploy.resources",
+	"pids_limit":      "use deploy.resources.limits.pids",
+	"scale":           "use deploy.replicas",
+	"platform":        "use deploy.placement.constraints",
+	"expose":          "ports of a service are reachable from its networks",
+}
+
+// Warnings are the fields of the service file which are not supported by swarm
+// and are ignored (like build), so a deployment does not silently miss settings
+func (d *DockerSwarm) Warnings() []string {
+	var warnings []string
+
+	for key := range d.Unknown {
+		if key == "version" || key == "name" || strings.HasPrefix(key, "x-") {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("%s is not supported and is ignored", key))
+	}
+
+	for _, name := range d.UnsetVariables {
+		warnings = append(warnings, fmt.Sprintf("variable %s is not set, an empty string is used", name))
+	}
+
+	for serviceName, service := range d.Services {
+		if service.Build != nil {
+			warnings = append(warnings, ignoredWarning(serviceName, "build"))
+		}
+
+		for key := range service.Unknown {
+			if strings.HasPrefix(key, "x-") {
+				continue
+			}
+			warnings = append(warnings, ignoredWarning(serviceName, key))
+		}
+
+		if service.Healthcheck != nil && service.Healthcheck.StartInterval != "" {
+			warnings = append(warnings, fmt.Sprintf("service %s: healthcheck.start_interval is not supported and is ignored", serviceName))
+		}
+
+		for _, port := range service.Ports {
+			if port.HostIP != "" {
+				warnings = append(warnings, fmt.Sprintf("service %s: host ip %s of port %s is not supported by swarm and is ignored", serviceName, port.HostIP, port.Target))
+			}
+		}
+
+		for _, volume := range service.Volumes {
+			if volume.Bind != nil && volume.Bind.SELinux != "" {
+				warnings = append(warnings, fmt.Sprintf("service %s: selinux relabeling of volume %s is not supported by swarm and is ignored", serviceName, volume.Target))
+			}
+		}
+	}
+
+	sort.Strings(warnings)
+	return warnings
+}
+
+func ignoredWarning(serviceName string, field string) string {
+	warning := fmt.Sprintf("service %s: %s is not supported by swarm and is ignored", serviceName, field)
+	if reason, ok := ignoredFields[field]; ok {
+		warning += " (" + reason + ")"
+	}
+	return warning
+}
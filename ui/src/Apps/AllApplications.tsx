@@ -27,6 +27,8 @@ type respData = {
 type appData = {
 	created_at: string;
 	health: string;
+	sync_status: string;
+	last_sync_error: string;
 	id: number;
 	last_synced_at: string;
 	name: string;
@@ -108,6 +110,10 @@ export default function AllApplications({ refresh }: { refresh: boolean }) {
 								{app.name}
 							</span>
 							<GetHealthBadge health={app.health} />
+							<GetSyncBadge
+								status={app.sync_status}
+								error={app.last_sync_error}
+							/>
 						</div>
 					</div>
 					<div className="md:flex md:items-center md:justify-start mt-4 text-sm md:gap-8">
@@ -182,6 +188,38 @@ function GetHealthBadge({ health }: { health: string }) {
 	}
 }
 
+function GetSyncBadge({ status, error }: { status: string; error: string }) {
+	switch (status) {
+		case "synced":
+			return (
+				<span className="ml-2 text-xs text-green-400 font-semibold rounded-lg py-1 px-2  bg-green-400/20">
+					synced
+				</span>
+			);
+		case "out_of_sync":
+			return (
+				<span className="ml-2 text-xs text-yellow-400 font-semibold rounded-lg py-1 px-2  bg-yellow-400/20">
+					out of sync
+				</span>
+			);
+		case "error":
+			return (
+				<span
+					title={error}
+					className="ml-2 text-xs text-red-400 font-semibold rounded-lg py-1 px-2  bg-red-400/20"
+				>
+					sync error
+				</span>
+			);
+		default:
+			return (
+				<span className="ml-2 text-xs text-gray-400 font-semibold rounded-lg py-1 px-2  bg-gray-400/20">
+					sync: unknown
+				</span>
+			);
+	}
+}
+
 export function GetSinceTime({ time }: { time: string }) {
 	const [currentTime, setCurrentTime] = useState(Date.now());
 
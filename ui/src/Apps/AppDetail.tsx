@@ -19,18 +19,31 @@ import { useParams, useNavigate } from "react-router-dom";
 import setTitle from "../lib/setTitle";
 import toast from "react-hot-toast";
 import { useQuery } from "@tanstack/react-query";
-import { MessageWithIcon } from "./AllApplications";
+import { GetSinceTime, MessageWithIcon } from "./AllApplications";
 import { DeleteIcon, ErrorIcon, RecreateIcon, Spinner } from "../lib/icon";
 import Tooltip from "../lib/Tooltip";
 
+type commit = {
+  sha: string;
+  author: string;
+  timestamp: string;
+  message: string;
+};
+
 type respData = {
   created_at: string;
   health: number;
   health_status: string;
+  sync_status: string;
+  last_sync_error: string;
+  last_sync_attempt_at: string;
   id: number;
   last_synced_at: string;
   name: string;
   refresh_timer: string;
+  resolved_revision: string;
+  synced_commit: commit;
+  warnings: string[] | null;
   source: {
     path: string;
     repoURL: string;
@@ -245,6 +258,8 @@ function ShowAppDetails({ name }: { name: string | undefined }) {
 
   return (
     <div>
+      <SyncedCommit commit={data.data.synced_commit} />
+      <Warnings warnings={data.data.warnings} />
       <pre>
         <code>{JSON.stringify(data, null, "\t")}</code>
       </pre>
@@ -252,6 +267,57 @@ function ShowAppDetails({ name }: { name: string | undefined }) {
   );
 }
 
+/**
+ * The git commit the application is synced to
+ */
+function SyncedCommit({ commit }: { commit: commit | undefined }) {
+  if (commit === undefined || commit.sha === "") {
+    return null;
+  }
+
+  return (
+    <div className="mb-8 p-4 rounded bg-[#373d49]/30">
+      <p className="opacity-50 text-sm mb-2">Synced commit</p>
+      <div className="flex items-center gap-4">
+        <span className="font-mono text-sm rounded py-1 px-2 bg-white/10">
+          {commit.sha.substring(0, 7)}
+        </span>
+        <span className="font-bold">{commit.message.split("\n")[0]}</span>
+      </div>
+      <div className="md:flex md:items-center md:justify-start mt-4 text-sm md:gap-8">
+        <div>
+          <span className="opacity-50">Author: </span>
+          {commit.author}
+        </div>
+        <div>
+          <span className="opacity-50">Committed: </span>
+          <GetSinceTime time={commit.timestamp} />
+        </div>
+      </div>
+    </div>
+  );
+}
+
+/**
+ * Fields of the service file which are ignored
+ */
+function Warnings({ warnings }: { warnings: string[] | null | undefined }) {
+  if (!warnings || warnings.length === 0) {
+    return null;
+  }
+
+  return (
+    <div className="mb-8 p-4 rounded bg-yellow-500/10">
+      <p className="opacity-50 text-sm mb-2">Warnings</p>
+      <ul className="list-disc list-inside text-sm">
+        {warnings.map((warning) => (
+          <li key={warning}>{warning}</li>
+        ))}
+      </ul>
+    </div>
+  );
+}
+
 /**
  * Delete Modal window
  */
@@ -47,6 +47,10 @@ func errorMsg(text string, args ...any) { //nolint
 }
 
 func GetSinceTime(t time.Time) string {
+	if t.IsZero() {
+		return "Never"
+	}
+
 	elapsed := time.Since(t).Milliseconds()
 
 	if elapsed == 0 {